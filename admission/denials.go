@@ -0,0 +1,241 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package admission
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// PodSecurityAdmission : the built-in Pod Security admission
+	PodSecurityAdmission Engine = "PodSecurity"
+	// PodSecurityPolicy : the PodSecurityPolicy admission of clusters older than 1.25
+	PodSecurityPolicy Engine = "PodSecurityPolicy"
+	// ValidatingAdmissionPolicy : the built-in admission of ValidatingAdmissionPolicies,
+	// whose CEL expressions validate requests
+	ValidatingAdmissionPolicy Engine = "ValidatingAdmissionPolicy"
+	// Webhook : an admission webhook of no known policy engine
+	Webhook Engine = "webhook"
+)
+
+// constraintsGroupVersion serves a resource per Gatekeeper ConstraintTemplate,
+// whose kind is the kind of its Constraints
+const constraintsGroupVersion = "constraints.gatekeeper.sh/v1beta1"
+
+var (
+	// webhookDenial matches the denial of an admission webhook, e.g.
+	// admission webhook "validation.gatekeeper.sh" denied the request: ...
+	webhookDenial = regexp.MustCompile(`(?s)admission webhook "([^"]+)" denied the request:?\s*(.*)`)
+	// gatekeeperViolation matches a violation of a Gatekeeper Constraint,
+	// one per line: [constraint-name] message
+	gatekeeperViolation = regexp.MustCompile(`(?m)^\[([a-z0-9][-a-z0-9.]*)\] (.*)$`)
+	// kyvernoPolicy and kyvernoRule match the lines naming a Kyverno policy,
+	// and each of its rules indented below it
+	kyvernoPolicy = regexp.MustCompile(`^(\S+):$`)
+	kyvernoRule   = regexp.MustCompile(`^  (\S+): (.*)$`)
+	// podSecurityDenial matches the denial of Pod Security admission, e.g.
+	// violates PodSecurity "restricted:latest": host namespaces (...)
+	podSecurityDenial = regexp.MustCompile(`violates PodSecurity "([^"]+)": ([^\n]*)`)
+	// policyDenial matches the denial of a ValidatingAdmissionPolicy, e.g.
+	// ValidatingAdmissionPolicy 'restrict-host-namespaces' with binding
+	// 'restrict-host-namespaces' denied request: ...
+	policyDenial = regexp.MustCompile(`ValidatingAdmissionPolicy '([^']+)' with binding '([^']+)' denied request: ([^\n]*)`)
+	// notAdmission matches the refusals of forbidden requests which come
+	// from no admission policy: RBAC, ResourceQuotas, LimitRanges and
+	// terminating namespaces
+	notAdmission = regexp.MustCompile(`cannot \S+ resource "|exceeded quota|failed quota|` +
+		`usage per (Container|Pod|PersistentVolumeClaim)|ratio per (Container|Pod)|because it is being terminated`)
+)
+
+// Policy is the policy, or the rule of a policy, behind a denial
+type Policy struct {
+	// Kind is the kind of the policy object, e.g. K8sPSPPrivilegedContainer
+	// for a Gatekeeper Constraint, or ClusterPolicy for Kyverno
+	Kind string
+	// Name is e.g. psp-privileged-container, web/restrict-images for a
+	// namespaced Kyverno Policy, or restricted:latest for Pod Security
+	Name string
+	// Template is the ConstraintTemplate of a Gatekeeper Constraint
+	Template string
+	// Rule is the rule of a Kyverno policy
+	Rule string
+	// Binding is the binding of a ValidatingAdmissionPolicy
+	Binding string
+	Message string
+}
+
+func (p Policy) String() string {
+	s := p.Name
+	if p.Kind != "" {
+		s = p.Kind + " " + s
+	}
+	if p.Template != "" {
+		s = "ConstraintTemplate " + p.Template + ", " + s
+	}
+	if p.Rule != "" {
+		s += " rule " + p.Rule
+	}
+	if p.Binding != "" {
+		s += " with binding " + p.Binding
+	}
+	return s
+}
+
+// Denial is a request refused by admission, with what refused it
+type Denial struct {
+	// Engine is empty if the denial comes from no known engine
+	Engine Engine
+	// Webhook is the name of the denying webhook, if any
+	Webhook  string
+	Policies []Policy
+}
+
+// String formats the denial as e.g.
+// "Gatekeeper webhook validation.gatekeeper.sh: K8sPSPPrivilegedContainer psp-privileged-container"
+func (d Denial) String() string {
+	s := string(d.Engine)
+	if s == "" {
+		s = "admission"
+	}
+	if d.Webhook != "" {
+		s += " webhook " + d.Webhook
+	}
+	if len(d.Policies) == 0 {
+		return s
+	}
+	policies := make([]string, len(d.Policies))
+	for i, p := range d.Policies {
+		policies[i] = p.String()
+	}
+	return s + ": " + strings.Join(policies, "; ")
+}
+
+// ParseDenial recognises the denial formats of Pod Security admission,
+// ValidatingAdmissionPolicies, PodSecurityPolicy, Gatekeeper, Kyverno and
+// admission webhooks in general in message, an API error or the failure a
+// controller reported creating its pods, and names the policies behind it.
+// It returns false if message is not an admission denial, e.g. a refusal
+// by RBAC or a ResourceQuota, which leaves the policy under probe untested.
+// The kinds of Gatekeeper Constraints are missing from their message,
+// ResolveConstraints looks them up.
+func ParseDenial(message string) (Denial, bool) {
+	if m := webhookDenial.FindStringSubmatch(message); m != nil {
+		d := Denial{Engine: Webhook, Webhook: m[1]}
+		switch {
+		case strings.HasSuffix(m[1], "gatekeeper.sh"):
+			d.Engine = Gatekeeper
+			for _, v := range gatekeeperViolation.FindAllStringSubmatch(m[2], -1) {
+				d.Policies = append(d.Policies, Policy{Name: v[1], Message: v[2]})
+			}
+		case strings.Contains(m[1], "kyverno"):
+			d.Engine = Kyverno
+			d.Policies = kyvernoPolicies(m[2])
+		}
+		return d, true
+	}
+	if matches := policyDenial.FindAllStringSubmatch(message, -1); matches != nil {
+		d := Denial{Engine: ValidatingAdmissionPolicy}
+		for _, m := range matches {
+			d.Policies = append(d.Policies, Policy{Kind: "ValidatingAdmissionPolicy", Name: m[1], Binding: m[2],
+				Message: m[3]})
+		}
+		return d, true
+	}
+	if m := podSecurityDenial.FindStringSubmatch(message); m != nil {
+		return Denial{Engine: PodSecurityAdmission, Policies: []Policy{{Name: m[1], Message: m[2]}}}, true
+	}
+	if strings.Contains(message, "unable to validate against any pod security policy") {
+		return Denial{Engine: PodSecurityPolicy}, true
+	}
+	if strings.Contains(message, "is forbidden: ") && !notAdmission.MatchString(message) {
+		return Denial{}, true
+	}
+	return Denial{}, false
+}
+
+// IsDenied reports whether message is an admission denial
+func IsDenied(message string) bool {
+	_, ok := ParseDenial(message)
+	return ok
+}
+
+// kyvernoPolicies reads the rules Kyverno lists under each policy blocking
+// the resource, e.g.
+//
+//	disallow-privileged-containers:
+//	  privileged-containers: 'validation error: Privileged mode is disallowed...'
+//
+// Namespaced Policies are named namespace/name, ClusterPolicies by name.
+func kyvernoPolicies(message string) []Policy {
+	var policies []Policy
+	policy := ""
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimRight(line, " \r")
+		if m := kyvernoPolicy.FindStringSubmatch(line); m != nil {
+			policy = m[1]
+			continue
+		}
+		if m := kyvernoRule.FindStringSubmatch(line); m != nil && policy != "" {
+			kind := "ClusterPolicy"
+			if strings.Contains(policy, "/") {
+				kind = "Policy"
+			}
+			policies = append(policies, Policy{Kind: kind, Name: policy, Rule: m[1],
+				Message: strings.Trim(m[2], "'")})
+			continue
+		}
+		// the message of a rule wraps onto lines indented further
+		if n := len(policies); n != 0 && strings.HasPrefix(line, "    ") {
+			policies[n-1].Message += " " + strings.Trim(strings.TrimSpace(line), "'")
+		}
+	}
+	return policies
+}
+
+// ResolveConstraints looks up the kind and ConstraintTemplate of the
+// Gatekeeper Constraints named by the denial. Constraints are cluster
+// scoped, and served under a resource per ConstraintTemplate.
+func ResolveConstraints(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface,
+	denial *Denial) error {
+	if denial.Engine != Gatekeeper || len(denial.Policies) == 0 {
+		return nil
+	}
+	resources, err := clientset.Discovery().ServerResourcesForGroupVersionWithContext(ctx, constraintsGroupVersion)
+	if err != nil {
+		return errors.New("Failed to discover gatekeeper constraints: " + err.Error())
+	}
+	gv, err := schema.ParseGroupVersion(constraintsGroupVersion)
+	if err != nil {
+		return err
+	}
+	for i := range denial.Policies {
+		p := &denial.Policies[i]
+		for _, r := range resources.APIResources {
+			if strings.Contains(r.Name, "/") {
+				// e.g. the status subresource
+				continue
+			}
+			_, err := dynamicClient.Resource(gv.WithResource(r.Name)).Get(ctx, p.Name, metav1.GetOptions{})
+			if kerr.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return errors.New("Failed to get gatekeeper constraint: " + err.Error())
+			}
+			// a ConstraintTemplate is named after the kind it defines, in lower case
+			p.Kind, p.Template = r.Kind, strings.ToLower(r.Kind)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package admission
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("recognising admission denials", func() {
+
+	DescribeTable("should name the policies behind a denial",
+		func(message string, expected Denial) {
+			denial, ok := ParseDenial(message)
+			Expect(ok).To(BeTrue())
+			Expect(denial).To(Equal(expected))
+		},
+		Entry("Gatekeeper",
+			`admission webhook "validation.gatekeeper.sh" denied the request: `+
+				"[psp-privileged-container] Privileged container is not allowed: nginx, securityContext: {\"privileged\": true}\n"+
+				"[psp-host-namespace] Sharing the host namespace is not allowed: nginx",
+			Denial{Engine: Gatekeeper, Webhook: "validation.gatekeeper.sh", Policies: []Policy{
+				{Name: "psp-privileged-container",
+					Message: "Privileged container is not allowed: nginx, securityContext: {\"privileged\": true}"},
+				{Name: "psp-host-namespace", Message: "Sharing the host namespace is not allowed: nginx"},
+			}}),
+		Entry("Kyverno",
+			"Error from server: error when creating \"pod.yaml\": admission webhook \"validate.kyverno.svc-fail\" "+
+				"denied the request: \n\nresource Pod/web/nginx was blocked due to the following policies \n\n"+
+				"disallow-privileged-containers:\n"+
+				"  privileged-containers: 'validation error: Privileged mode is disallowed. The fields\n"+
+				"    spec.containers[*].securityContext.privileged must be unset or set to false.'\n"+
+				"web/disallow-host-namespaces:\n"+
+				"  host-namespaces: 'validation error: Sharing the host namespaces is disallowed.'\n",
+			Denial{Engine: Kyverno, Webhook: "validate.kyverno.svc-fail", Policies: []Policy{
+				{Kind: "ClusterPolicy", Name: "disallow-privileged-containers", Rule: "privileged-containers",
+					Message: "validation error: Privileged mode is disallowed. The fields " +
+						"spec.containers[*].securityContext.privileged must be unset or set to false."},
+				{Kind: "Policy", Name: "web/disallow-host-namespaces", Rule: "host-namespaces",
+					Message: "validation error: Sharing the host namespaces is disallowed."},
+			}}),
+		Entry("Pod Security admission",
+			`pods "nginx" is forbidden: violates PodSecurity "baseline:latest": host namespaces `+
+				`(hostNetwork=true), privileged (container "nginx" must not set securityContext.privileged=true)`,
+			Denial{Engine: PodSecurityAdmission, Policies: []Policy{{Name: "baseline:latest",
+				Message: `host namespaces (hostNetwork=true), privileged ` +
+					`(container "nginx" must not set securityContext.privileged=true)`}}}),
+		Entry("PodSecurityPolicy",
+			`pods "nginx" is forbidden: unable to validate against any pod security policy: `+
+				`[spec.securityContext.hostNetwork: Invalid value: true: Host network is not allowed to be used]`,
+			Denial{Engine: PodSecurityPolicy}),
+		Entry("another webhook",
+			`admission webhook "deny.example.com" denied the request: images must come from registry.example.com`,
+			Denial{Engine: Webhook, Webhook: "deny.example.com"}),
+		Entry("another admission plugin",
+			`pods "nginx" is forbidden: image policy webhook backend denied one or more images: nginx`,
+			Denial{}),
+	)
+
+	It("should not take other errors for denials", func() {
+		Expect(IsDenied(`pods "nginx" not found`)).To(BeFalse())
+		Expect(IsDenied(`pods is forbidden: User "alice" cannot create resource "pods" in API group "" ` +
+			`in the namespace "web"`)).To(BeFalse())
+		Expect(IsDenied(`pods "nginx" is forbidden: exceeded quota: compute, requested: limits.cpu=2, ` +
+			`used: limits.cpu=4, limited: limits.cpu=4`)).To(BeFalse())
+		Expect(IsDenied(`pods "nginx" is forbidden: maximum cpu usage per Container is 2, but limit is 4`)).To(BeFalse())
+		Expect(IsDenied(`Internal error occurred: failed calling webhook "validation.gatekeeper.sh"`)).To(BeFalse())
+	})
+
+	It("should format the denial", func() {
+		denial := Denial{Engine: Gatekeeper, Webhook: "validation.gatekeeper.sh", Policies: []Policy{
+			{Kind: "K8sPSPPrivilegedContainer", Name: "psp-privileged-container", Template: "k8spspprivilegedcontainer"},
+		}}
+		Expect(denial.String()).To(Equal("Gatekeeper webhook validation.gatekeeper.sh: " +
+			"ConstraintTemplate k8spspprivilegedcontainer, K8sPSPPrivilegedContainer psp-privileged-container"))
+		Expect(Denial{}.String()).To(Equal("admission"))
+	})
+
+	It("should resolve the kind and template of gatekeeper constraints", func() {
+		clientset := fake.NewClientset()
+		clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{{
+			GroupVersion: constraintsGroupVersion,
+			APIResources: []metav1.APIResource{
+				{Name: "k8spsphostnamespace", Kind: "K8sPSPHostNamespace"},
+				{Name: "k8spspprivilegedcontainer", Kind: "K8sPSPPrivilegedContainer"},
+				{Name: "k8spspprivilegedcontainer/status", Kind: "K8sPSPPrivilegedContainer"},
+			},
+		}}
+		hostNamespace := schema.GroupVersionResource{Group: "constraints.gatekeeper.sh", Version: "v1beta1",
+			Resource: "k8spsphostnamespace"}
+		privilegedContainer := hostNamespace.GroupVersion().WithResource("k8spspprivilegedcontainer")
+		dynamicClient := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{
+				hostNamespace:       "K8sPSPHostNamespaceList",
+				privilegedContainer: "K8sPSPPrivilegedContainerList",
+			})
+		// gatekeeper names the resource of a constraint kind after it, in lower case
+		constraint := &unstructured.Unstructured{}
+		constraint.SetAPIVersion(constraintsGroupVersion)
+		constraint.SetKind("K8sPSPPrivilegedContainer")
+		constraint.SetName("psp-privileged-container")
+		_, err := dynamicClient.Resource(privilegedContainer).Create(context.Background(), constraint, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		denial := Denial{Engine: Gatekeeper, Policies: []Policy{{Name: "psp-privileged-container"}, {Name: "deleted"}}}
+		Expect(ResolveConstraints(context.Background(), clientset, dynamicClient, &denial)).To(Succeed())
+		Expect(denial.Policies).To(Equal([]Policy{
+			{Kind: "K8sPSPPrivilegedContainer", Name: "psp-privileged-container", Template: "k8spspprivilegedcontainer"},
+			{Name: "deleted"},
+		}))
+	})
+})
+
+var _ = Describe("discovering policy engines", func() {
+
+	It("should find the engines by the API groups they serve", func() {
+		clientset := fake.NewClientset()
+		clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+			{GroupVersion: "v1"},
+			{GroupVersion: "kyverno.io/v1"},
+			{GroupVersion: "constraints.gatekeeper.sh/v1beta1"},
+			{GroupVersion: "templates.gatekeeper.sh/v1"},
+		}
+		Expect(Engines(context.Background(), clientset)).To(Equal([]Engine{Gatekeeper, Kyverno}))
+
+		clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = nil
+		Expect(Engines(context.Background(), clientset)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,51 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package admission
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Engine is what enforces admission policies: a policy engine behind
+// admission webhooks, or a built-in admission plugin
+type Engine string
+
+const (
+	// Gatekeeper : OPA Gatekeeper, enforcing Constraints of ConstraintTemplates
+	Gatekeeper Engine = "Gatekeeper"
+	// Kyverno : Kyverno, enforcing the rules of ClusterPolicies and Policies
+	Kyverno Engine = "Kyverno"
+)
+
+// engineGroups are the API groups each engine serves its policies under
+var engineGroups = map[string]Engine{
+	"templates.gatekeeper.sh":   Gatekeeper,
+	"constraints.gatekeeper.sh": Gatekeeper,
+	"kyverno.io":                Kyverno,
+}
+
+// Engines returns the webhook policy engines installed in the cluster,
+// found through the API groups they serve
+func Engines(ctx context.Context, clientset kubernetes.Interface) ([]Engine, error) {
+	groups, err := clientset.Discovery().ServerGroupsWithContext(ctx)
+	if err != nil {
+		return nil, errors.New("Failed to discover API groups: " + err.Error())
+	}
+	found := map[Engine]bool{}
+	for _, g := range groups.Groups {
+		if engine, ok := engineGroups[g.Name]; ok {
+			found[engine] = true
+		}
+	}
+	engines := make([]Engine, 0, len(found))
+	for engine := range found {
+		engines = append(engines, engine)
+	}
+	sort.Slice(engines, func(i, j int) bool { return engines[i] < engines[j] })
+	return engines, nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package admission
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// BoundPolicy is a ValidatingAdmissionPolicy with the bindings enforcing it
+type BoundPolicy struct {
+	Policy   admissionregistrationv1.ValidatingAdmissionPolicy
+	Bindings []admissionregistrationv1.ValidatingAdmissionPolicyBinding
+}
+
+// String formats the policy as e.g.
+// "restrict-host-namespaces (binding restrict-host-namespaces: Deny)"
+func (b BoundPolicy) String() string {
+	if len(b.Bindings) == 0 {
+		return b.Policy.Name + " (unbound)"
+	}
+	bindings := make([]string, len(b.Bindings))
+	for i, binding := range b.Bindings {
+		bindings[i] = "binding " + binding.Name + ": " + formatBinding(binding)
+	}
+	return b.Policy.Name + " (" + strings.Join(bindings, "; ") + ")"
+}
+
+// ValidatingAdmissionPolicies returns every ValidatingAdmissionPolicy with
+// its bindings. Bindings of a policy which does not exist enforce nothing,
+// and are left out. Clusters older than 1.30, which do not serve the v1
+// API, have no policies.
+func ValidatingAdmissionPolicies(ctx context.Context, clientset kubernetes.Interface) ([]BoundPolicy, error) {
+	api := clientset.AdmissionregistrationV1()
+	policies, err := api.ValidatingAdmissionPolicies().List(ctx, metav1.ListOptions{})
+	if kerr.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("Failed to list validatingadmissionpolicies: " + err.Error())
+	}
+	bindings, err := api.ValidatingAdmissionPolicyBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.New("Failed to list validatingadmissionpolicybindings: " + err.Error())
+	}
+
+	bound := make([]BoundPolicy, len(policies.Items))
+	for i, policy := range policies.Items {
+		bound[i].Policy = policy
+		for _, binding := range bindings.Items {
+			if binding.Spec.PolicyName == policy.Name {
+				bound[i].Bindings = append(bound[i].Bindings, binding)
+			}
+		}
+	}
+	return bound, nil
+}
+
+// ValidatingAdmissionPolicyFindings reports the policies taking part in the
+// checks which do not deny every request they fail: those bound by no
+// binding, those whose bindings only Warn or Audit, and those only denying
+// within the scope of a binding, so the probes are admitted despite them.
+// Each finding names the checks the policy could undermine.
+func ValidatingAdmissionPolicyFindings(policies []BoundPolicy) []report.Finding {
+	var findings []report.Finding
+	for _, p := range policies {
+		undermined := policyUndermined(p.Policy)
+		if len(undermined) == 0 || deniesEverywhere(p.Bindings) {
+			continue
+		}
+		message := "is bound by no ValidatingAdmissionPolicyBinding, so it is not enforced"
+		if len(p.Bindings) != 0 {
+			names := make([]string, len(p.Bindings))
+			scoped := false
+			for i, binding := range p.Bindings {
+				names[i] = binding.Name + " (" + formatBinding(binding) + ")"
+				scoped = scoped || denies(binding)
+			}
+			message = "is only bound to warn or audit, by " + strings.Join(names, ", ") +
+				", so the requests it fails are admitted"
+			if scoped {
+				message = "is only bound to deny within a scope, by " + strings.Join(names, ", ") +
+					", so the requests it fails outside of it are admitted"
+			}
+		}
+		findings = append(findings, report.Finding{
+			Check:    checks.AdmissionPolicyActions,
+			Resource: "ValidatingAdmissionPolicy " + p.Policy.Name,
+			Message:  message + ", undermining " + formatChecks(undermined),
+		})
+	}
+	return findings
+}
+
+// policyUndermined returns the checks whose probes the policy takes part in
+// denying. Policies match requests with the rules of webhooks.
+func policyUndermined(policy admissionregistrationv1.ValidatingAdmissionPolicy) []checks.ID {
+	if policy.Spec.MatchConstraints == nil {
+		return nil
+	}
+	w := webhook{}
+	for _, rule := range policy.Spec.MatchConstraints.ResourceRules {
+		w.rules = append(w.rules, rule.RuleWithOperations)
+	}
+	return w.undermined()
+}
+
+// deniesEverywhere reports whether any of the bindings denies every
+// request the policy fails
+func deniesEverywhere(bindings []admissionregistrationv1.ValidatingAdmissionPolicyBinding) bool {
+	for _, binding := range bindings {
+		if denies(binding) && len(bindingScope(binding)) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// denies reports whether the binding denies the requests the policy fails
+// within its scope
+func denies(binding admissionregistrationv1.ValidatingAdmissionPolicyBinding) bool {
+	for _, action := range binding.Spec.ValidationActions {
+		if action == admissionregistrationv1.Deny {
+			return true
+		}
+	}
+	return false
+}
+
+// bindingScope lists what narrows the requests the binding applies the
+// policy to: the matchResources of the binding, and a paramRef admitting
+// the requests whose params are missing. It is empty for a binding
+// applying the policy to every request the policy matches.
+func bindingScope(binding admissionregistrationv1.ValidatingAdmissionPolicyBinding) []string {
+	var scope []string
+	if match := binding.Spec.MatchResources; match != nil {
+		if selects(match.NamespaceSelector) {
+			scope = append(scope, "namespaceSelector")
+		}
+		if selects(match.ObjectSelector) {
+			scope = append(scope, "objectSelector")
+		}
+		if len(match.ResourceRules) != 0 {
+			scope = append(scope, "resourceRules")
+		}
+		if len(match.ExcludeResourceRules) != 0 {
+			scope = append(scope, "excludeResourceRules")
+		}
+	}
+	if ref := binding.Spec.ParamRef; ref != nil &&
+		(ref.ParameterNotFoundAction == nil || *ref.ParameterNotFoundAction != admissionregistrationv1.DenyAction) {
+		scope = append(scope, "paramRef")
+	}
+	return scope
+}
+
+// selects reports whether the label selector leaves anything out; a nil
+// or empty one matches everything
+func selects(selector *metav1.LabelSelector) bool {
+	return selector != nil && (len(selector.MatchLabels) != 0 || len(selector.MatchExpressions) != 0)
+}
+
+// formatBinding formats the validation actions and the scope of the
+// binding, e.g. "Deny, scoped by namespaceSelector"
+func formatBinding(binding admissionregistrationv1.ValidatingAdmissionPolicyBinding) string {
+	s := formatActions(binding.Spec.ValidationActions)
+	if scope := bindingScope(binding); len(scope) != 0 {
+		s += ", scoped by " + strings.Join(scope, ", ")
+	}
+	return s
+}
+
+// formatActions lists the validation actions, e.g. "Warn, Audit"
+func formatActions(actions []admissionregistrationv1.ValidationAction) string {
+	names := make([]string, len(actions))
+	for i, action := range actions {
+		names[i] = string(action)
+	}
+	return strings.Join(names, ", ")
+}
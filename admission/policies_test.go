@@ -0,0 +1,119 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package admission
+
+import (
+	"context"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("auditing ValidatingAdmissionPolicies", func() {
+
+	policy := func(name string, resources ...string) *admissionregistrationv1.ValidatingAdmissionPolicy {
+		return &admissionregistrationv1.ValidatingAdmissionPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: admissionregistrationv1.ValidatingAdmissionPolicySpec{
+				MatchConstraints: &admissionregistrationv1.MatchResources{
+					ResourceRules: []admissionregistrationv1.NamedRuleWithOperations{{
+						RuleWithOperations: admissionregistrationv1.RuleWithOperations{
+							Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+							Rule: admissionregistrationv1.Rule{
+								APIGroups:   []string{""},
+								APIVersions: []string{"v1"},
+								Resources:   resources,
+							},
+						},
+					}},
+				},
+			},
+		}
+	}
+	binding := func(name, policy string,
+		actions ...admissionregistrationv1.ValidationAction) *admissionregistrationv1.ValidatingAdmissionPolicyBinding {
+		return &admissionregistrationv1.ValidatingAdmissionPolicyBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: admissionregistrationv1.ValidatingAdmissionPolicyBindingSpec{
+				PolicyName:        policy,
+				ValidationActions: actions,
+			},
+		}
+	}
+	deny, warn, audit := admissionregistrationv1.Deny, admissionregistrationv1.Warn, admissionregistrationv1.Audit
+
+	It("should report the policies denying no request or only within a scope", func() {
+		// denies only within the namespaces labelled as production
+		scoped := binding("deny-host-ports-production", "deny-host-ports", deny)
+		scoped.Spec.MatchResources = &admissionregistrationv1.MatchResources{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "production"}},
+		}
+		// denies the requests whose params are missing too, so it denies everywhere
+		denyMissing := admissionregistrationv1.DenyAction
+		params := binding("deny-capabilities", "deny-capabilities", deny)
+		params.Spec.ParamRef = &admissionregistrationv1.ParamRef{
+			Name: "capabilities", ParameterNotFoundAction: &denyMissing,
+		}
+		params.Spec.MatchResources = &admissionregistrationv1.MatchResources{
+			NamespaceSelector: &metav1.LabelSelector{},
+		}
+		clientset := fake.NewClientset(
+			policy("deny-host-namespaces", "pods"),
+			binding("deny-host-namespaces", "deny-host-namespaces", deny),
+			binding("deny-host-namespaces-audit", "deny-host-namespaces", audit),
+			policy("deny-privileged", "pods"),
+			binding("deny-privileged-dry-run", "deny-privileged", warn, audit),
+			policy("deny-host-path", "pods"),
+			policy("deny-host-ports", "pods"), scoped,
+			policy("deny-capabilities", "pods"), params,
+			// enforces nothing, and takes part in no check
+			policy("require-service-owner", "services"),
+			binding("deleted-policy", "deleted", deny),
+		)
+		policies, err := ValidatingAdmissionPolicies(context.Background(), clientset)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(6))
+		Expect(policies[1].String()).To(Equal("deny-host-namespaces (binding deny-host-namespaces: Deny; " +
+			"binding deny-host-namespaces-audit: Audit)"))
+		Expect(policies[2].String()).To(Equal("deny-host-path (unbound)"))
+		Expect(policies[3].String()).To(Equal("deny-host-ports " +
+			"(binding deny-host-ports-production: Deny, scoped by namespaceSelector)"))
+
+		Expect(ValidatingAdmissionPolicyFindings(policies)).To(Equal([]report.Finding{{
+			Check:    checks.AdmissionPolicyActions,
+			Resource: "ValidatingAdmissionPolicy deny-host-path",
+			Message: "is bound by no ValidatingAdmissionPolicyBinding, so it is not enforced, " +
+				"undermining the PodSpec checks",
+		}, {
+			Check:    checks.AdmissionPolicyActions,
+			Resource: "ValidatingAdmissionPolicy deny-host-ports",
+			Message: "is only bound to deny within a scope, by deny-host-ports-production " +
+				"(Deny, scoped by namespaceSelector), so the requests it fails outside of it are admitted, " +
+				"undermining the PodSpec checks",
+		}, {
+			Check:    checks.AdmissionPolicyActions,
+			Resource: "ValidatingAdmissionPolicy deny-privileged",
+			Message: "is only bound to warn or audit, by deny-privileged-dry-run (Warn, Audit), " +
+				"so the requests it fails are admitted, undermining the PodSpec checks",
+		}}))
+	})
+
+	It("should attribute denials to the policy and binding", func() {
+		denial, ok := ParseDenial(`pods "nginx" is forbidden: ValidatingAdmissionPolicy 'deny-privileged' ` +
+			`with binding 'deny-privileged' denied request: privileged containers are not allowed`)
+		Expect(ok).To(BeTrue())
+		Expect(denial).To(Equal(Denial{Engine: ValidatingAdmissionPolicy, Policies: []Policy{{
+			Kind: "ValidatingAdmissionPolicy", Name: "deny-privileged", Binding: "deny-privileged",
+			Message: "privileged containers are not allowed",
+		}}}))
+		Expect(denial.String()).To(Equal("ValidatingAdmissionPolicy: " +
+			"ValidatingAdmissionPolicy deny-privileged with binding deny-privileged"))
+	})
+})
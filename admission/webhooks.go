@@ -0,0 +1,384 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+// Package admission reads how admission control is set up in the cluster:
+// the webhooks policy engines such as Gatekeeper and Kyverno register, the
+// policies they enforce, and the built-in ValidatingAdmissionPolicies.
+// Probes denied by admission are attributed to the policy behind the
+// denial, and set-ups letting probes through are reported.
+package admission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// maxWebhookTimeout is the longest timeout, in seconds, a webhook may set.
+// It is the default timeout, longer ones hold every matching request up
+// while the webhook is unavailable.
+const maxWebhookTimeout = 10
+
+// namespaceNameLabel is set by the API server on every namespace to its name
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// webhook is a webhook of a Validating or MutatingWebhookConfiguration
+type webhook struct {
+	// configuration is e.g. "ValidatingWebhookConfiguration gatekeeper"
+	configuration     string
+	validating        bool
+	name              string
+	failurePolicy     *admissionregistrationv1.FailurePolicyType
+	namespaceSelector *metav1.LabelSelector
+	objectSelector    *metav1.LabelSelector
+	rules             []admissionregistrationv1.RuleWithOperations
+	timeoutSeconds    *int32
+	service           *admissionregistrationv1.ServiceReference
+}
+
+// resourceRequest is a request a webhook rule may match
+type resourceRequest struct {
+	group     string
+	resource  string
+	operation admissionregistrationv1.OperationType
+}
+
+// coverage maps the requests a webhook intercepts to the checks whose
+// probes it takes part in denying
+var coverage = []struct {
+	request resourceRequest
+	checks  []checks.ID
+}{
+	{resourceRequest{"", "pods", admissionregistrationv1.Create}, checks.PodSpecChecks},
+	{resourceRequest{"", "pods", admissionregistrationv1.Update}, []checks.ID{checks.PodUpdate}},
+	{resourceRequest{"", "pods/ephemeralcontainers", admissionregistrationv1.Update},
+		[]checks.ID{checks.EphemeralContainers}},
+	{resourceRequest{"rbac.authorization.k8s.io", "clusterrolebindings", admissionregistrationv1.Create},
+		[]checks.ID{checks.ClusterAdminBindings, checks.RBACEscalation}},
+	{resourceRequest{"rbac.authorization.k8s.io", "rolebindings", admissionregistrationv1.Create},
+		[]checks.ID{checks.ClusterAdminBindings, checks.RBACEscalation}},
+	{resourceRequest{"", "namespaces", admissionregistrationv1.Update}, []checks.ID{checks.NamespacePodSecurity}},
+	{resourceRequest{"networking.k8s.io", "networkpolicies", admissionregistrationv1.Delete},
+		[]checks.ID{checks.DefaultDenyNetworkPolicy, checks.NetworkPolicyCoverage}},
+}
+
+// controllerRequests are the creations of the workload controllers, whose
+// pods are created by the controllers rather than by the user
+var controllerRequests = []resourceRequest{
+	{"apps", "deployments", admissionregistrationv1.Create},
+	{"apps", "replicasets", admissionregistrationv1.Create},
+	{"apps", "daemonsets", admissionregistrationv1.Create},
+	{"apps", "statefulsets", admissionregistrationv1.Create},
+	{"batch", "jobs", admissionregistrationv1.Create},
+	{"batch", "cronjobs", admissionregistrationv1.Create},
+}
+
+// Webhooks audits the admission webhooks, which policy engines such as
+// Gatekeeper and Kyverno enforce their policies through. It reports the
+// webhooks which:
+//   - fail open, with failurePolicy Ignore
+//   - exclude namespaces other than the exempt ones, or let namespaces or
+//     objects opt out through labels
+//   - validate pods, but not their updates nor ephemeral containers, or
+//     validate workload controllers but not the pods they create
+//   - time out after more than 10 seconds
+//   - call a service which does not exist or has no ready endpoint
+//
+// Each finding names the checks whose probes the webhook takes part in
+// denying, and which the set-up could thus undermine. Webhooks taking part
+// in none of the checks are left out.
+func Webhooks(ctx context.Context, clientset kubernetes.Interface, exempt []string) ([]report.Finding, error) {
+	webhooks, err := listWebhooks(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []report.Finding
+	for _, w := range webhooks {
+		add := func(message string, undermined []checks.ID) {
+			if len(undermined) == 0 {
+				return
+			}
+			findings = append(findings, report.Finding{
+				Check:    checks.AdmissionWebhooks,
+				Resource: w.configuration,
+				Message:  w.name + ": " + message + ", undermining " + formatChecks(undermined),
+			})
+		}
+		undermined := w.undermined()
+
+		if w.failurePolicy != nil && *w.failurePolicy == admissionregistrationv1.Ignore {
+			add("failurePolicy is Ignore, so requests are admitted whenever the webhook fails", undermined)
+		}
+		for _, message := range selectorExclusions(w.namespaceSelector, exempt) {
+			add(message, undermined)
+		}
+		if w.objectSelector != nil &&
+			(len(w.objectSelector.MatchLabels) != 0 || len(w.objectSelector.MatchExpressions) != 0) {
+			add("objectSelector lets objects opt out through their own labels", undermined)
+		}
+		if w.validating {
+			for _, missing := range w.missingRules() {
+				add(missing.message, missing.checks)
+			}
+		}
+		if w.timeoutSeconds != nil && *w.timeoutSeconds > maxWebhookTimeout {
+			add(fmt.Sprintf("timeoutSeconds is %d, longer than %ds", *w.timeoutSeconds, maxWebhookTimeout),
+				undermined)
+		}
+		if w.service != nil {
+			message, err := serviceUnreachable(ctx, clientset, w.service)
+			if err != nil {
+				return nil, err
+			}
+			if message != "" {
+				add(message, undermined)
+			}
+		}
+	}
+	return findings, nil
+}
+
+// listWebhooks lists the webhooks of every Validating and
+// MutatingWebhookConfiguration
+func listWebhooks(ctx context.Context, clientset kubernetes.Interface) ([]webhook, error) {
+	api := clientset.AdmissionregistrationV1()
+	validating, err := api.ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.New("Failed to list validatingwebhookconfigurations: " + err.Error())
+	}
+	mutating, err := api.MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.New("Failed to list mutatingwebhookconfigurations: " + err.Error())
+	}
+
+	var webhooks []webhook
+	for _, c := range validating.Items {
+		for _, w := range c.Webhooks {
+			webhooks = append(webhooks, webhook{"ValidatingWebhookConfiguration " + c.Name, true, w.Name,
+				w.FailurePolicy, w.NamespaceSelector, w.ObjectSelector, w.Rules, w.TimeoutSeconds,
+				w.ClientConfig.Service})
+		}
+	}
+	for _, c := range mutating.Items {
+		for _, w := range c.Webhooks {
+			webhooks = append(webhooks, webhook{"MutatingWebhookConfiguration " + c.Name, false, w.Name,
+				w.FailurePolicy, w.NamespaceSelector, w.ObjectSelector, w.Rules, w.TimeoutSeconds,
+				w.ClientConfig.Service})
+		}
+	}
+	return webhooks, nil
+}
+
+// intercepts reports whether one of the webhook rules matches the request
+func (w *webhook) intercepts(request resourceRequest) bool {
+	for _, rule := range w.rules {
+		if ruleMatches(rule, request) {
+			return true
+		}
+	}
+	return false
+}
+
+// undermined returns the checks whose probes the webhook takes part in denying
+func (w *webhook) undermined() []checks.ID {
+	var ids []checks.ID
+	for _, c := range coverage {
+		if w.intercepts(c.request) {
+			ids = appendChecks(ids, c.checks...)
+		}
+	}
+	// validating a controller denies the pods it would create
+	for _, request := range controllerRequests {
+		if w.intercepts(request) {
+			ids = appendChecks(ids, checks.PodSpecChecks...)
+		}
+	}
+	return ids
+}
+
+// missingRule is a request a validating webhook should intercept, given
+// the ones it does
+type missingRule struct {
+	message string
+	checks  []checks.ID
+}
+
+// missingRules returns the pod requests the webhook lets through although
+// it validates pods or workload controllers
+func (w *webhook) missingRules() []missingRule {
+	createsPods := w.intercepts(resourceRequest{"", "pods", admissionregistrationv1.Create})
+	var missing []missingRule
+	if !createsPods {
+		for _, request := range controllerRequests {
+			if w.intercepts(request) {
+				missing = append(missing, missingRule{"validates " + request.resource +
+					" but not pods, which can be created directly", checks.PodSpecChecks})
+				break
+			}
+		}
+		return missing
+	}
+	if !w.intercepts(resourceRequest{"", "pods", admissionregistrationv1.Update}) {
+		missing = append(missing, missingRule{"validates the creation of pods but not their updates",
+			[]checks.ID{checks.PodUpdate}})
+	}
+	if !w.intercepts(resourceRequest{"", "pods/ephemeralcontainers", admissionregistrationv1.Update}) {
+		missing = append(missing, missingRule{"validates pods but not pods/ephemeralcontainers",
+			[]checks.ID{checks.EphemeralContainers}})
+	}
+	return missing
+}
+
+// ruleMatches reports whether the rule matches the request, with the
+// wildcard semantics of webhook rules: "*" matches every resource but no
+// subresource, "*/*" every resource and subresource, and "pods/*" every
+// subresource of pods
+func ruleMatches(rule admissionregistrationv1.RuleWithOperations, request resourceRequest) bool {
+	operation := false
+	for _, op := range rule.Operations {
+		if op == admissionregistrationv1.OperationAll || op == request.operation {
+			operation = true
+		}
+	}
+	if !operation || !containsAny(rule.APIGroups, "*", request.group) {
+		return false
+	}
+	resource, subresource := request.resource, ""
+	if i := strings.Index(resource, "/"); i >= 0 {
+		resource, subresource = resource[:i], resource[i+1:]
+	}
+	if subresource == "" {
+		return containsAny(rule.Resources, "*", "*/*", resource)
+	}
+	return containsAny(rule.Resources, "*/*", "*/"+subresource, resource+"/*", request.resource)
+}
+
+// selectorExclusions describes how the namespaceSelector leaves namespaces
+// out, other than the exempt ones
+func selectorExclusions(selector *metav1.LabelSelector, exempt []string) []string {
+	if selector == nil {
+		return nil
+	}
+	var exclusions []string
+	keys := make([]string, 0, len(selector.MatchLabels))
+	for key := range selector.MatchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == namespaceNameLabel {
+			exclusions = append(exclusions, "namespaceSelector only applies to namespace "+selector.MatchLabels[key])
+		} else {
+			exclusions = append(exclusions, "namespaceSelector only applies to namespaces labelled "+
+				key+"="+selector.MatchLabels[key]+", which anyone able to label namespaces can remove")
+		}
+	}
+	for _, e := range selector.MatchExpressions {
+		switch {
+		case e.Key != namespaceNameLabel:
+			exclusions = append(exclusions, "namespaceSelector depends on the namespace label "+e.Key+
+				", which anyone able to label namespaces can set or remove")
+		case e.Operator == metav1.LabelSelectorOpIn:
+			exclusions = append(exclusions, "namespaceSelector only applies to namespaces "+strings.Join(e.Values, ", "))
+		case e.Operator == metav1.LabelSelectorOpNotIn:
+			var excluded []string
+			for _, ns := range e.Values {
+				if !containsAny(exempt, ns) {
+					excluded = append(excluded, ns)
+				}
+			}
+			if len(excluded) != 0 {
+				exclusions = append(exclusions, "namespaceSelector excludes namespaces "+strings.Join(excluded, ", "))
+			}
+		}
+	}
+	return exclusions
+}
+
+// serviceUnreachable describes why the webhook service cannot be reached,
+// or returns an empty string if it has a ready endpoint
+func serviceUnreachable(ctx context.Context, clientset kubernetes.Interface,
+	service *admissionregistrationv1.ServiceReference) (string, error) {
+	name := service.Namespace + "/" + service.Name
+	_, err := clientset.CoreV1().Services(service.Namespace).Get(ctx, service.Name, metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		return "service " + name + " does not exist", nil
+	}
+	if err != nil {
+		return "", errors.New("Failed to get service: " + err.Error())
+	}
+	slices, err := clientset.DiscoveryV1().EndpointSlices(service.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + service.Name,
+	})
+	if err != nil {
+		return "", errors.New("Failed to list endpointslices: " + err.Error())
+	}
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				return "", nil
+			}
+		}
+	}
+	return "service " + name + " has no ready endpoint", nil
+}
+
+// formatChecks lists the checks, naming the PodSpec checks as a whole
+func formatChecks(ids []checks.ID) string {
+	var names []string
+	podSpec := true
+	for _, id := range checks.PodSpecChecks {
+		podSpec = podSpec && containsCheck(ids, id)
+	}
+	if podSpec {
+		names = append(names, "the PodSpec checks")
+	}
+	for _, id := range ids {
+		if !podSpec || !containsCheck(checks.PodSpecChecks, id) {
+			names = append(names, string(id))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// appendChecks appends the ids missing from checks
+func appendChecks(ids []checks.ID, more ...checks.ID) []checks.ID {
+	for _, id := range more {
+		if !containsCheck(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func containsCheck(ids []checks.ID, id checks.ID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny reports whether values hold any of wanted
+func containsAny(values []string, wanted ...string) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
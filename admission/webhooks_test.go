@@ -0,0 +1,143 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package admission
+
+import (
+	"context"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("auditing admission webhooks", func() {
+
+	rule := func(group string, operations []admissionregistrationv1.OperationType,
+		resources ...string) admissionregistrationv1.RuleWithOperations {
+		return admissionregistrationv1.RuleWithOperations{
+			Operations: operations,
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{group},
+				APIVersions: []string{"*"},
+				Resources:   resources,
+			},
+		}
+	}
+	all := []admissionregistrationv1.OperationType{admissionregistrationv1.OperationAll}
+	create := []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+	ignore := admissionregistrationv1.Ignore
+	fail := admissionregistrationv1.Fail
+	timeout := int32(30)
+	ready := true
+
+	It("should report the set-ups undermining the checks", func() {
+		clientset := fake.NewClientset(
+			&admissionregistrationv1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "gatekeeper-validating-webhook-configuration"},
+				Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+					Name: "validation.gatekeeper.sh",
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{Service: &admissionregistrationv1.ServiceReference{
+						Namespace: "gatekeeper-system", Name: "gatekeeper-webhook-service",
+					}},
+					Rules:         []admissionregistrationv1.RuleWithOperations{rule("*", all, "*", "pods/ephemeralcontainers")},
+					FailurePolicy: &ignore,
+					NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+						Key:      "kubernetes.io/metadata.name",
+						Operator: metav1.LabelSelectorOpNotIn,
+						Values:   []string{"kube-system", "gatekeeper-system"},
+					}, {
+						Key:      "admission.gatekeeper.sh/ignore",
+						Operator: metav1.LabelSelectorOpDoesNotExist,
+					}}},
+				}},
+			},
+			&admissionregistrationv1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "deployments"},
+				Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+					Name: "deployments.example.com",
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{Service: &admissionregistrationv1.ServiceReference{
+						Namespace: "policy", Name: "webhook",
+					}},
+					Rules:          []admissionregistrationv1.RuleWithOperations{rule("apps", create, "deployments")},
+					FailurePolicy:  &fail,
+					TimeoutSeconds: &timeout,
+				}},
+			},
+			// cert-manager takes part in none of the checks
+			&admissionregistrationv1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "cert-manager-webhook"},
+				Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+					Name:          "webhook.cert-manager.io",
+					Rules:         []admissionregistrationv1.RuleWithOperations{rule("cert-manager.io", all, "*/*")},
+					FailurePolicy: &ignore,
+				}},
+			},
+			&admissionregistrationv1.MutatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "kyverno-resource-mutating-webhook-cfg"},
+				Webhooks: []admissionregistrationv1.MutatingWebhook{{
+					Name:           "mutate.kyverno.svc-fail",
+					Rules:          []admissionregistrationv1.RuleWithOperations{rule("", create, "pods")},
+					FailurePolicy:  &fail,
+					ObjectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				}},
+			},
+			&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "policy"}},
+			&discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "webhook-x2x9z", Namespace: "policy",
+					Labels: map[string]string{discoveryv1.LabelServiceName: "webhook"},
+				},
+				Endpoints: []discoveryv1.Endpoint{{
+					Addresses:  []string{"10.0.0.1"},
+					Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+				}},
+			},
+		)
+
+		findings, err := Webhooks(context.Background(), clientset, []string{"kube-system"})
+		Ω(err).Should(BeNil())
+		gatekeeper := func(message string) report.Finding {
+			return report.Finding{
+				Check:    checks.AdmissionWebhooks,
+				Resource: "ValidatingWebhookConfiguration gatekeeper-validating-webhook-configuration",
+				Message: "validation.gatekeeper.sh: " + message + ", undermining the PodSpec checks, " +
+					"pod-update, ephemeral-containers, cluster-admin-bindings, rbac-escalation, " +
+					"namespace-pod-security, " +
+					"default-deny-network-policy, network-policy-coverage",
+			}
+		}
+		Expect(findings).To(ConsistOf(
+			gatekeeper("failurePolicy is Ignore, so requests are admitted whenever the webhook fails"),
+			gatekeeper("namespaceSelector excludes namespaces gatekeeper-system"),
+			gatekeeper("namespaceSelector depends on the namespace label admission.gatekeeper.sh/ignore, "+
+				"which anyone able to label namespaces can set or remove"),
+			gatekeeper("service gatekeeper-system/gatekeeper-webhook-service does not exist"),
+			report.Finding{
+				Check:    checks.AdmissionWebhooks,
+				Resource: "ValidatingWebhookConfiguration deployments",
+				Message: "deployments.example.com: validates deployments but not pods, " +
+					"which can be created directly, undermining the PodSpec checks",
+			},
+			report.Finding{
+				Check:    checks.AdmissionWebhooks,
+				Resource: "ValidatingWebhookConfiguration deployments",
+				Message: "deployments.example.com: timeoutSeconds is 30, longer than 10s, " +
+					"undermining the PodSpec checks",
+			},
+			report.Finding{
+				Check:    checks.AdmissionWebhooks,
+				Resource: "MutatingWebhookConfiguration kyverno-resource-mutating-webhook-cfg",
+				Message: "mutate.kyverno.svc-fail: objectSelector lets objects opt out through their own labels, " +
+					"undermining the PodSpec checks",
+			},
+		))
+	})
+})
@@ -0,0 +1,256 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+// Package audit evaluates the workloads already running in the cluster
+// against the PodSpec checks. Admission probes only show what would be
+// refused now, the audit finds what was admitted before the policy applied.
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+)
+
+// workload is an object with a PodSpec found in the cluster
+type workload struct {
+	kind     string
+	meta     metav1.ObjectMeta
+	spec     *v1.PodSpec
+	specPath *field.Path
+	// annotations are those of the pod or pod template, found at
+	// annotationsPath
+	annotations     map[string]string
+	annotationsPath *field.Path
+}
+
+// ref identifies an object by kind, namespace and name
+type ref struct {
+	kind, namespace, name string
+}
+
+func (r ref) String() string {
+	if r.namespace == "" {
+		return r.kind + " " + r.name
+	}
+	return r.kind + " " + r.namespace + "/" + r.name
+}
+
+func (w *workload) ref() ref {
+	return ref{w.kind, w.meta.Namespace, w.meta.Name}
+}
+
+// controllerRef returns the ref of the object controlling meta, if any
+func controllerRef(meta metav1.ObjectMeta) (ref, bool) {
+	if owner := metav1.GetControllerOfNoCopy(&meta); owner != nil {
+		return ref{owner.Kind, meta.Namespace, owner.Name}, true
+	}
+	return ref{}, false
+}
+
+// Workloads lists Pods, Deployments, DaemonSets, StatefulSets, Jobs and
+// CronJobs in namespace, or in all namespaces if namespace is empty, and
+// evaluates their PodSpecs. A workload whose controller is itself audited,
+// e.g. a pod of a Deployment, is only reported where it differs from the
+// template of that controller, e.g. an injected ephemeral container, so
+// every breach of the template is reported once. Each finding names the
+// workload and the top level object owning it.
+func Workloads(ctx context.Context, clientset kubernetes.Interface, namespace string) ([]report.Finding, error) {
+	inv, err := listInventory(ctx, clientset, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []report.Finding
+	for _, w := range inv.workloads {
+		owner := inv.owner(w.ref())
+		violations := w.evaluate(w)
+		if controller, ok := inv.auditedController(w); ok {
+			violations = differing(violations, controller.evaluate(w))
+		}
+		for _, v := range violations {
+			f := report.Finding{
+				Check:    v.Check,
+				Resource: w.ref().String(),
+				Message:  v.String(),
+			}
+			if owner != w.ref() {
+				f.Owner = owner.String()
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
+
+// evaluate evaluates the pod spec and annotations of w as if they were
+// found at the paths of at, so the violations of a controller's template
+// compare with those of the workloads it controls
+func (w *workload) evaluate(at *workload) []checks.Violation {
+	violations := checks.EvaluatePodSpec(w.spec, at.specPath)
+	return append(violations, checks.EvaluatePodAnnotations(w.annotations, at.annotationsPath)...)
+}
+
+// differing returns the violations which are not in template
+func differing(violations, template []checks.Violation) []checks.Violation {
+	inTemplate := map[string]bool{}
+	for _, v := range template {
+		inTemplate[string(v.Check)+" "+v.String()] = true
+	}
+	var differ []checks.Violation
+	for _, v := range violations {
+		if !inTemplate[string(v.Check)+" "+v.String()] {
+			differ = append(differ, v)
+		}
+	}
+	return differ
+}
+
+// inventory holds the workloads of the cluster, and the controller of every
+// listed object, ReplicaSets included, to resolve the top level owners
+type inventory struct {
+	workloads   []*workload
+	audited     map[ref]*workload
+	controllers map[ref]ref
+}
+
+// topLevel returns the workloads whose controller is not audited on its
+// own, so every workload is counted once through its controller
+func (inv *inventory) topLevel() []*workload {
+	var top []*workload
+	for _, w := range inv.workloads {
+		if _, ok := inv.auditedController(w); !ok {
+			top = append(top, w)
+		}
+	}
+	return top
+}
+
+// auditedController returns the nearest workload up the controller
+// references of w which is itself audited, e.g. the Deployment of a pod
+// through its ReplicaSet
+func (inv *inventory) auditedController(w *workload) (*workload, bool) {
+	r := w.ref()
+	// bound the walk in case of a cycle in the owner references
+	for i := 0; i < 10; i++ {
+		controller, ok := inv.controllers[r]
+		if !ok {
+			break
+		}
+		if a, ok := inv.audited[controller]; ok {
+			return a, true
+		}
+		r = controller
+	}
+	return nil, false
+}
+
+// owner follows the controller references from r up to the top level
+// owner. It returns r if the object has no controller.
+func (inv *inventory) owner(r ref) ref {
+	// bound the walk in case of a cycle in the owner references
+	for i := 0; i < 10; i++ {
+		owner, ok := inv.controllers[r]
+		if !ok {
+			break
+		}
+		r = owner
+	}
+	return r
+}
+
+// listInventory lists every audited workload kind in namespace
+func listInventory(ctx context.Context, clientset kubernetes.Interface, namespace string) (*inventory, error) {
+	inv := &inventory{audited: map[ref]*workload{}, controllers: map[ref]ref{}}
+	opts := metav1.ListOptions{}
+	template := field.NewPath("spec", "template")
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, errors.New("Failed to list pods: " + err.Error())
+	}
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		inv.add("Pod", p.ObjectMeta, p.Annotations, &p.Spec, nil)
+	}
+
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, errors.New("Failed to list deployments: " + err.Error())
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		inv.add("Deployment", d.ObjectMeta, d.Spec.Template.Annotations, &d.Spec.Template.Spec, template)
+	}
+
+	replicaSets, err := clientset.AppsV1().ReplicaSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, errors.New("Failed to list replicasets: " + err.Error())
+	}
+	for _, rs := range replicaSets.Items {
+		if controller, ok := controllerRef(rs.ObjectMeta); ok {
+			inv.controllers[ref{"ReplicaSet", rs.Namespace, rs.Name}] = controller
+		}
+	}
+
+	daemonSets, err := clientset.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, errors.New("Failed to list daemonsets: " + err.Error())
+	}
+	for i := range daemonSets.Items {
+		d := &daemonSets.Items[i]
+		inv.add("DaemonSet", d.ObjectMeta, d.Spec.Template.Annotations, &d.Spec.Template.Spec, template)
+	}
+
+	statefulSets, err := clientset.AppsV1().StatefulSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, errors.New("Failed to list statefulsets: " + err.Error())
+	}
+	for i := range statefulSets.Items {
+		s := &statefulSets.Items[i]
+		inv.add("StatefulSet", s.ObjectMeta, s.Spec.Template.Annotations, &s.Spec.Template.Spec, template)
+	}
+
+	jobs, err := clientset.BatchV1().Jobs(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, errors.New("Failed to list jobs: " + err.Error())
+	}
+	for i := range jobs.Items {
+		j := &jobs.Items[i]
+		inv.add("Job", j.ObjectMeta, j.Spec.Template.Annotations, &j.Spec.Template.Spec, template)
+	}
+
+	cronJobs, err := clientset.BatchV1().CronJobs(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, errors.New("Failed to list cronjobs: " + err.Error())
+	}
+	for i := range cronJobs.Items {
+		c := &cronJobs.Items[i]
+		inv.add("CronJob", c.ObjectMeta, c.Spec.JobTemplate.Spec.Template.Annotations,
+			&c.Spec.JobTemplate.Spec.Template.Spec, field.NewPath("spec", "jobTemplate", "spec", "template"))
+	}
+	return inv, nil
+}
+
+// add adds a workload whose pod template is at templatePath, or a pod if
+// templatePath is nil
+func (inv *inventory) add(kind string, meta metav1.ObjectMeta, annotations map[string]string, spec *v1.PodSpec,
+	templatePath *field.Path) {
+	w := &workload{kind: kind, meta: meta, spec: spec, annotations: annotations,
+		specPath: field.NewPath("spec"), annotationsPath: field.NewPath("metadata", "annotations")}
+	if templatePath != nil {
+		w.specPath = templatePath.Child("spec")
+		w.annotationsPath = templatePath.Child("metadata", "annotations")
+	}
+	inv.workloads = append(inv.workloads, w)
+	inv.audited[w.ref()] = w
+	if controller, ok := controllerRef(meta); ok {
+		inv.controllers[w.ref()] = controller
+	}
+}
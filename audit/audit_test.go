@@ -0,0 +1,123 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package audit
+
+import (
+	"context"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// controlledBy returns object metadata controlled by the given kind and name
+func controlledBy(name string, kind string, owner string) metav1.ObjectMeta {
+	isController := true
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "web",
+		OwnerReferences: []metav1.OwnerReference{
+			{Kind: kind, Name: owner, Controller: &isController},
+		},
+	}
+}
+
+var _ = Describe("auditing workloads", func() {
+
+	hostNetwork := v1.PodSpec{
+		HostNetwork: true,
+		SecurityContext: &v1.PodSecurityContext{
+			SeccompProfile: &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault},
+		},
+		Containers: []v1.Container{{Name: "nginx", Image: "nginx"}},
+	}
+
+	It("should report a controller once, not its pods", func() {
+		clientset := fake.NewClientset(
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "web"},
+				Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+						v1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix + "nginx": v1.DeprecatedAppArmorBetaProfileNameUnconfined,
+					}},
+					Spec: hostNetwork,
+				}},
+			},
+			&appsv1.ReplicaSet{ObjectMeta: controlledBy("nginx-5d4f", "Deployment", "nginx")},
+			&v1.Pod{ObjectMeta: controlledBy("nginx-5d4f-x2x9", "ReplicaSet", "nginx-5d4f"), Spec: hostNetwork},
+		)
+
+		findings, err := Workloads(context.Background(), clientset, "")
+		Ω(err).Should(BeNil())
+		Expect(findings).To(ConsistOf(report.Finding{
+			Check:    checks.HostNetwork,
+			Resource: "Deployment web/nginx",
+			Message:  "spec.template.spec.hostNetwork: Host network is not allowed to be used",
+		}, report.Finding{
+			Check:    checks.AppArmor,
+			Resource: "Deployment web/nginx",
+			Message: "spec.template.metadata.annotations[container.apparmor.security.beta.kubernetes.io/nginx]: " +
+				"Unconfined AppArmor profile is not allowed",
+		}))
+	})
+
+	It("should report the pods differing from the template of their controller under the owner", func() {
+		privileged := true
+		debugged := *hostNetwork.DeepCopy()
+		debugged.EphemeralContainers = []v1.EphemeralContainer{{EphemeralContainerCommon: v1.EphemeralContainerCommon{
+			Name: "debugger", Image: "busybox",
+			SecurityContext: &v1.SecurityContext{Privileged: &privileged},
+		}}}
+		// a pod of the ReplicaSet the rollout is replacing
+		previous := *hostNetwork.DeepCopy()
+		previous.HostPID = true
+		clientset := fake.NewClientset(
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "web"},
+				Spec:       appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: hostNetwork}},
+			},
+			&appsv1.ReplicaSet{ObjectMeta: controlledBy("nginx-5d4f", "Deployment", "nginx")},
+			&appsv1.ReplicaSet{ObjectMeta: controlledBy("nginx-7b9c", "Deployment", "nginx")},
+			&v1.Pod{ObjectMeta: controlledBy("nginx-5d4f-x2x9", "ReplicaSet", "nginx-5d4f"), Spec: debugged},
+			&v1.Pod{ObjectMeta: controlledBy("nginx-7b9c-k8v2", "ReplicaSet", "nginx-7b9c"), Spec: previous},
+		)
+
+		findings, err := Workloads(context.Background(), clientset, "web")
+		Ω(err).Should(BeNil())
+		Expect(findings).To(ConsistOf(report.Finding{
+			Check:    checks.HostNetwork,
+			Resource: "Deployment web/nginx",
+			Message:  "spec.template.spec.hostNetwork: Host network is not allowed to be used",
+		}, report.Finding{
+			Check:    checks.Privileged,
+			Resource: "Pod web/nginx-5d4f-x2x9",
+			Owner:    "Deployment web/nginx",
+			Message:  "spec.ephemeralContainers[0].securityContext.privileged: Privileged containers are not allowed",
+		}, report.Finding{
+			Check:    checks.HostPID,
+			Resource: "Pod web/nginx-7b9c-k8v2",
+			Owner:    "Deployment web/nginx",
+			Message:  "spec.hostPID: Host PID is not allowed to be used",
+		}))
+	})
+
+	It("should report workloads of unaudited controllers with their top level owner", func() {
+		clientset := fake.NewClientset(
+			&appsv1.ReplicaSet{ObjectMeta: controlledBy("proxy-1", "Rollout", "proxy")},
+			&v1.Pod{ObjectMeta: controlledBy("proxy-1-abcd", "ReplicaSet", "proxy-1"), Spec: hostNetwork},
+		)
+
+		findings, err := Workloads(context.Background(), clientset, "web")
+		Ω(err).Should(BeNil())
+		Expect(findings).To(HaveLen(1))
+		Expect(findings[0].Resource).To(Equal("Pod web/proxy-1-abcd"))
+		Expect(findings[0].Owner).To(Equal("Rollout web/proxy"))
+	})
+})
@@ -0,0 +1,148 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NetworkPolicyChecks are the checks NetworkPolicies evaluates
+var NetworkPolicyChecks = []checks.ID{
+	checks.DefaultDenyNetworkPolicy,
+	checks.NetworkPolicyCoverage,
+}
+
+// NetworkPolicies audits the NetworkPolicies of namespace, or of all
+// namespaces if namespace is empty, leaving out the exempt namespaces:
+//   - every namespace must have a policy selecting all of its pods, and
+//     allowing them neither ingress nor egress traffic, so traffic has to
+//     be allowed explicitly
+//   - every pod must be selected by a policy, as the traffic of pods no
+//     policy selects is not restricted at all
+//
+// Pods using the host network are left out, since policies do not apply
+// to them.
+func NetworkPolicies(ctx context.Context, clientset kubernetes.Interface, namespace string,
+	exempt []string) ([]report.Finding, error) {
+	exempted := map[string]bool{}
+	for _, ns := range exempt {
+		exempted[ns] = true
+	}
+
+	var namespaces []string
+	if namespace != "" {
+		namespaces = []string{namespace}
+	} else {
+		list, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, errors.New("Failed to list namespaces: " + err.Error())
+		}
+		for _, ns := range list.Items {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	list, err := clientset.NetworkingV1().NetworkPolicies(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.New("Failed to list networkpolicies: " + err.Error())
+	}
+	policies := map[string][]networkingv1.NetworkPolicy{}
+	for _, p := range list.Items {
+		policies[p.Namespace] = append(policies[p.Namespace], p)
+	}
+
+	var findings []report.Finding
+	for _, ns := range namespaces {
+		if exempted[ns] {
+			continue
+		}
+		for _, policyType := range []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress} {
+			if !hasDefaultDeny(policies[ns], policyType) {
+				findings = append(findings, report.Finding{
+					Check:    checks.DefaultDenyNetworkPolicy,
+					Resource: ref{"Namespace", "", ns}.String(),
+					Message:  "no NetworkPolicy denies all " + string(policyType) + " traffic by default",
+				})
+			}
+		}
+	}
+
+	inv, err := listInventory(ctx, clientset, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range inv.workloads {
+		if w.kind != "Pod" || exempted[w.meta.Namespace] || w.spec.HostNetwork {
+			continue
+		}
+		if selected(policies[w.meta.Namespace], w.meta.Labels) {
+			continue
+		}
+		f := report.Finding{
+			Check:    checks.NetworkPolicyCoverage,
+			Resource: w.ref().String(),
+			Message:  "no NetworkPolicy selects the pod",
+		}
+		if owner := inv.owner(w.ref()); owner != w.ref() {
+			f.Owner = owner.String()
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// hasDefaultDeny reports whether one of the policies selects every pod of
+// its namespace and allows no traffic of policyType
+func hasDefaultDeny(policies []networkingv1.NetworkPolicy, policyType networkingv1.PolicyType) bool {
+	for _, p := range policies {
+		if len(p.Spec.PodSelector.MatchLabels) != 0 || len(p.Spec.PodSelector.MatchExpressions) != 0 {
+			continue
+		}
+		if !hasPolicyType(p.Spec, policyType) {
+			continue
+		}
+		if policyType == networkingv1.PolicyTypeIngress && len(p.Spec.Ingress) == 0 ||
+			policyType == networkingv1.PolicyTypeEgress && len(p.Spec.Egress) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPolicyType reports whether the policy applies to traffic of
+// policyType. Without policyTypes, a policy applies to ingress, and to
+// egress if it has egress rules.
+func hasPolicyType(spec networkingv1.NetworkPolicySpec, policyType networkingv1.PolicyType) bool {
+	if len(spec.PolicyTypes) == 0 {
+		return policyType == networkingv1.PolicyTypeIngress || len(spec.Egress) != 0
+	}
+	for _, t := range spec.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// selected reports whether one of the policies selects pods with podLabels
+func selected(policies []networkingv1.NetworkPolicy, podLabels map[string]string) bool {
+	for _, p := range policies {
+		selector, err := metav1.LabelSelectorAsSelector(&p.Spec.PodSelector)
+		if err != nil {
+			// the API server validates selectors, skip it rather than fail the audit
+			continue
+		}
+		if selector.Matches(labels.Set(podLabels)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package audit
+
+import (
+	"context"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("auditing network policies", func() {
+
+	It("should report the namespaces without default-deny policies and the pods no policy selects", func() {
+		pod := func(namespace, name string, labels map[string]string, hostNetwork bool) *v1.Pod {
+			return &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+				Spec:       v1.PodSpec{HostNetwork: hostNetwork},
+			}
+		}
+		clientset := fake.NewClientset(
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web"}},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "api"}},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "batch"}},
+			&networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "default-deny", Namespace: "web"},
+				Spec: networkingv1.NetworkPolicySpec{PolicyTypes: []networkingv1.PolicyType{
+					networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress,
+				}},
+			},
+			// without policyTypes, a policy without egress rules only applies to ingress
+			&networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "deny-ingress", Namespace: "api"},
+			},
+			// allows all egress, so it is not a default deny
+			&networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "allow-egress", Namespace: "api"},
+				Spec: networkingv1.NetworkPolicySpec{
+					PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+					Egress:      []networkingv1.NetworkPolicyEgressRule{{}},
+				},
+			},
+			&networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "frontend", Namespace: "api"},
+				Spec: networkingv1.NetworkPolicySpec{
+					PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}},
+				},
+			},
+			pod("web", "nginx", nil, false),
+			pod("api", "frontend", map[string]string{"app": "frontend"}, false),
+			pod("api", "backend", map[string]string{"app": "backend"}, false),
+			pod("batch", "worker", nil, false),
+			pod("batch", "node-exporter", nil, true),
+			pod("kube-system", "coredns", nil, false),
+		)
+
+		findings, err := NetworkPolicies(context.Background(), clientset, "", []string{"kube-system"})
+		Ω(err).Should(BeNil())
+		Expect(findings).To(ConsistOf(
+			report.Finding{
+				Check:    checks.DefaultDenyNetworkPolicy,
+				Resource: "Namespace api",
+				Message:  "no NetworkPolicy denies all Egress traffic by default",
+			},
+			report.Finding{
+				Check:    checks.DefaultDenyNetworkPolicy,
+				Resource: "Namespace batch",
+				Message:  "no NetworkPolicy denies all Ingress traffic by default",
+			},
+			report.Finding{
+				Check:    checks.DefaultDenyNetworkPolicy,
+				Resource: "Namespace batch",
+				Message:  "no NetworkPolicy denies all Egress traffic by default",
+			},
+			report.Finding{
+				Check:    checks.NetworkPolicyCoverage,
+				Resource: "Pod batch/worker",
+				Message:  "no NetworkPolicy selects the pod",
+			},
+		))
+	})
+})
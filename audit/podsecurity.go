@@ -0,0 +1,140 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// podSecurityLabelPrefix prefixes the namespace labels configuring Pod
+// Security admission, e.g. pod-security.kubernetes.io/enforce-version
+const podSecurityLabelPrefix = "pod-security.kubernetes.io/"
+
+// PodSecurityModes are the Pod Security admission modes, in the order they
+// are reported
+var PodSecurityModes = []string{"enforce", "audit", "warn"}
+
+// podSecurityLevels are the valid Pod Security levels
+var podSecurityLevels = map[string]bool{"privileged": true, "baseline": true, "restricted": true}
+
+// PodSecurityMode is the level and version a namespace sets for one mode.
+// Level is empty if the mode is not set.
+type PodSecurityMode struct {
+	Level   string
+	Version string
+}
+
+func (m PodSecurityMode) String() string {
+	if m.Level == "" {
+		return "unset"
+	}
+	version := m.Version
+	if version == "" {
+		version = "latest"
+	}
+	return m.Level + "@" + version
+}
+
+// NamespacePodSecurity is the Pod Security configuration of a namespace,
+// by mode
+type NamespacePodSecurity struct {
+	Namespace string
+	Modes     map[string]PodSecurityMode
+}
+
+// String formats the configuration as
+// "enforce=restricted@latest audit=unset warn=restricted@v1.30"
+func (n NamespacePodSecurity) String() string {
+	s := ""
+	for i, mode := range PodSecurityModes {
+		if i > 0 {
+			s += " "
+		}
+		s += mode + "=" + n.Modes[mode].String()
+	}
+	return s
+}
+
+// PodSecurity reads the Pod Security labels of namespace, or of every
+// namespace if namespace is empty
+func PodSecurity(ctx context.Context, clientset kubernetes.Interface, namespace string) ([]NamespacePodSecurity, error) {
+	opts := metav1.ListOptions{}
+	if namespace != "" {
+		opts.FieldSelector = "metadata.name=" + namespace
+	}
+	list, err := clientset.CoreV1().Namespaces().List(ctx, opts)
+	if err != nil {
+		return nil, errors.New("Failed to list namespaces: " + err.Error())
+	}
+	var namespaces []NamespacePodSecurity
+	for _, ns := range list.Items {
+		if namespace != "" && ns.Name != namespace {
+			// the fake clientset used in tests ignores field selectors
+			continue
+		}
+		n := NamespacePodSecurity{Namespace: ns.Name, Modes: map[string]PodSecurityMode{}}
+		for _, mode := range PodSecurityModes {
+			n.Modes[mode] = PodSecurityMode{
+				Level:   ns.Labels[podSecurityLabelPrefix+mode],
+				Version: ns.Labels[podSecurityLabelPrefix+mode+"-version"],
+			}
+		}
+		namespaces = append(namespaces, n)
+	}
+	return namespaces, nil
+}
+
+// PodSecurityFindings reports the namespaces setting no enforce label, or
+// enforcing the privileged level or an invalid one, unless they are exempt.
+// Exempt namespaces which enforce baseline or restricted, or which do not
+// exist, are reported as well, so the exemptions do not outlive their need.
+func PodSecurityFindings(namespaces []NamespacePodSecurity, exempt []string) []report.Finding {
+	exempted := map[string]bool{}
+	for _, ns := range exempt {
+		exempted[ns] = true
+	}
+	var findings []report.Finding
+	add := func(namespace string, message string) {
+		findings = append(findings, report.Finding{
+			Check:    checks.NamespacePodSecurity,
+			Resource: ref{"Namespace", "", namespace}.String(),
+			Message:  message,
+		})
+	}
+
+	found := map[string]bool{}
+	for _, n := range namespaces {
+		found[n.Namespace] = true
+		enforce := n.Modes["enforce"]
+		var message string
+		switch {
+		case enforce.Level == "":
+			// the AdmissionConfiguration of the API server may still enforce a
+			// default level, which the labels do not show
+			message = "sets no pod-security.kubernetes.io/enforce label, so only the cluster default level applies"
+		case enforce.Level == "privileged":
+			message = "enforces the privileged Pod Security level"
+		case !podSecurityLevels[enforce.Level]:
+			message = "enforces the invalid Pod Security level " + enforce.Level
+		}
+		switch {
+		case message != "" && !exempted[n.Namespace]:
+			add(n.Namespace, message+" ("+n.String()+")")
+		case message == "" && exempted[n.Namespace]:
+			add(n.Namespace, "is exempt but enforces "+enforce.String()+", the exemption can be removed")
+		}
+	}
+	for _, ns := range exempt {
+		if !found[ns] {
+			add(ns, "is exempt but does not exist, the exemption can be removed")
+		}
+	}
+	return findings
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package audit
+
+import (
+	"context"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("auditing the Pod Security labels", func() {
+
+	namespace := func(name string, labels map[string]string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	clientset := fake.NewClientset(
+		namespace("web", map[string]string{
+			"pod-security.kubernetes.io/enforce":      "restricted",
+			"pod-security.kubernetes.io/warn":         "restricted",
+			"pod-security.kubernetes.io/warn-version": "v1.30",
+		}),
+		namespace("api", map[string]string{"pod-security.kubernetes.io/audit": "restricted"}),
+		namespace("kube-system", map[string]string{"pod-security.kubernetes.io/enforce": "privileged"}),
+		namespace("monitoring", map[string]string{"pod-security.kubernetes.io/enforce": "baseline"}),
+	)
+
+	It("should read the level and version of every mode", func() {
+		namespaces, err := PodSecurity(context.Background(), clientset, "web")
+		Ω(err).Should(BeNil())
+		Expect(namespaces).To(HaveLen(1))
+		Expect(namespaces[0].String()).To(Equal("enforce=restricted@latest audit=unset warn=restricted@v1.30"))
+	})
+
+	It("should report the namespaces not enforcing baseline or restricted, and the stale exemptions", func() {
+		namespaces, err := PodSecurity(context.Background(), clientset, "")
+		Ω(err).Should(BeNil())
+		Expect(PodSecurityFindings(namespaces, []string{"kube-system", "monitoring", "legacy"})).To(ConsistOf(
+			report.Finding{
+				Check:    checks.NamespacePodSecurity,
+				Resource: "Namespace api",
+				Message: "sets no pod-security.kubernetes.io/enforce label, so only the cluster default level applies " +
+					"(enforce=unset audit=restricted@latest warn=unset)",
+			},
+			report.Finding{
+				Check:    checks.NamespacePodSecurity,
+				Resource: "Namespace monitoring",
+				Message:  "is exempt but enforces baseline@latest, the exemption can be removed",
+			},
+			report.Finding{
+				Check:    checks.NamespacePodSecurity,
+				Resource: "Namespace legacy",
+				Message:  "is exempt but does not exist, the exemption can be removed",
+			},
+		))
+	})
+})
@@ -0,0 +1,134 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package audit
+
+import (
+	"context"
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SecretChecks are the checks Secrets evaluates
+var SecretChecks = []checks.ID{
+	checks.SecretEnvVars,
+	checks.PrivilegedSecretMounts,
+}
+
+// privilegedChecks are the PodSpec checks whose breach gives a pod's
+// containers access to the host, and thus lets them read what the kubelet
+// mounts into any other pod of the node
+var privilegedChecks = map[checks.ID]bool{
+	checks.Privileged:  true,
+	checks.HostPID:     true,
+	checks.HostIPC:     true,
+	checks.HostNetwork: true,
+}
+
+// Secrets audits how the workloads of namespace, or of all namespaces if
+// namespace is empty, consume secrets, leaving out the exempt namespaces,
+// whose CNI and CSI agents need both the host and secrets:
+//   - containers must read secrets from files rather than from environment
+//     variables, which leak through logs and crash dumps (CIS 5.4.1)
+//   - secrets must not be mounted into privileged pods, or pods sharing a
+//     host namespace, which any compromise of the node exposes
+func Secrets(ctx context.Context, clientset kubernetes.Interface, namespace string,
+	exempt []string) ([]report.Finding, error) {
+	exempted := map[string]bool{}
+	for _, ns := range exempt {
+		exempted[ns] = true
+	}
+
+	inv, err := listInventory(ctx, clientset, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []report.Finding
+	for _, w := range inv.topLevel() {
+		if exempted[w.meta.Namespace] {
+			continue
+		}
+		owner := inv.owner(w.ref())
+		add := func(id checks.ID, p *field.Path, message string) {
+			f := report.Finding{Check: id, Resource: w.ref().String(), Message: p.String() + ": " + message}
+			if owner != w.ref() {
+				f.Owner = owner.String()
+			}
+			findings = append(findings, f)
+		}
+
+		eachContainerEnv(w.spec, w.specPath, func(env []v1.EnvVar, envFrom []v1.EnvFromSource, p *field.Path) {
+			for i, e := range env {
+				if e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil {
+					ref := e.ValueFrom.SecretKeyRef
+					add(checks.SecretEnvVars, p.Child("env").Index(i).Child("valueFrom", "secretKeyRef"),
+						"exposes key "+ref.Key+" of Secret "+ref.Name+" as environment variable "+e.Name)
+				}
+			}
+			for i, e := range envFrom {
+				if e.SecretRef != nil {
+					add(checks.SecretEnvVars, p.Child("envFrom").Index(i).Child("secretRef"),
+						"exposes every key of Secret "+e.SecretRef.Name+" as environment variables")
+				}
+			}
+		})
+
+		var breaches []string
+		for _, v := range checks.EvaluatePodSpec(w.spec, w.specPath) {
+			if privilegedChecks[v.Check] && !containsString(breaches, string(v.Check)) {
+				breaches = append(breaches, string(v.Check))
+			}
+		}
+		if len(breaches) == 0 {
+			continue
+		}
+		message := " into a pod breaching " + strings.Join(breaches, ", ")
+		for i, volume := range w.spec.Volumes {
+			p := w.specPath.Child("volumes").Index(i)
+			if volume.Secret != nil {
+				add(checks.PrivilegedSecretMounts, p.Child("secret"),
+					"mounts Secret "+volume.Secret.SecretName+message)
+			}
+			if volume.Projected == nil {
+				continue
+			}
+			for j, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					add(checks.PrivilegedSecretMounts, p.Child("projected", "sources").Index(j).Child("secret"),
+						"mounts Secret "+source.Secret.Name+message)
+				}
+			}
+		}
+	}
+	return findings, nil
+}
+
+// eachContainerEnv calls fn with the environment, and the path, of every
+// init, regular and ephemeral container in spec
+func eachContainerEnv(spec *v1.PodSpec, fldPath *field.Path,
+	fn func(env []v1.EnvVar, envFrom []v1.EnvFromSource, p *field.Path)) {
+	for i, c := range spec.InitContainers {
+		fn(c.Env, c.EnvFrom, fldPath.Child("initContainers").Index(i))
+	}
+	for i, c := range spec.Containers {
+		fn(c.Env, c.EnvFrom, fldPath.Child("containers").Index(i))
+	}
+	for i, c := range spec.EphemeralContainers {
+		fn(c.Env, c.EnvFrom, fldPath.Child("ephemeralContainers").Index(i))
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
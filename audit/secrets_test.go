@@ -0,0 +1,109 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package audit
+
+import (
+	"context"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("auditing secrets", func() {
+
+	It("should report secrets read from the environment or mounted into privileged pods", func() {
+		privileged := true
+		clientset := fake.NewClientset(
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "web"},
+				Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+					Containers: []v1.Container{{
+						Name: "nginx",
+						Env: []v1.EnvVar{
+							{Name: "MODE", Value: "production"},
+							{Name: "DB_PASSWORD", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "db"},
+								Key:                  "password",
+							}}},
+						},
+						EnvFrom: []v1.EnvFromSource{{SecretRef: &v1.SecretEnvSource{
+							LocalObjectReference: v1.LocalObjectReference{Name: "api-keys"},
+						}}},
+					}},
+					Volumes: []v1.Volume{{Name: "tls", VolumeSource: v1.VolumeSource{
+						Secret: &v1.SecretVolumeSource{SecretName: "tls"},
+					}}},
+				}}},
+			},
+			&appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "monitoring"},
+				Spec: appsv1.DaemonSetSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+					HostNetwork: true,
+					Containers: []v1.Container{{
+						Name:            "agent",
+						SecurityContext: &v1.SecurityContext{Privileged: &privileged},
+					}},
+					Volumes: []v1.Volume{
+						{Name: "credentials", VolumeSource: v1.VolumeSource{
+							Secret: &v1.SecretVolumeSource{SecretName: "credentials"},
+						}},
+						{Name: "bundle", VolumeSource: v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{
+							Sources: []v1.VolumeProjection{
+								{ServiceAccountToken: &v1.ServiceAccountTokenProjection{Path: "token"}},
+								{Secret: &v1.SecretProjection{LocalObjectReference: v1.LocalObjectReference{Name: "ca"}}},
+							},
+						}}},
+					},
+				}}},
+			},
+			// exempt, like the CNI agents of the cluster components
+			&appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "cilium", Namespace: "kube-system"},
+				Spec: appsv1.DaemonSetSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+					HostNetwork: true,
+					Containers:  []v1.Container{{Name: "cilium-agent"}},
+					Volumes: []v1.Volume{{Name: "clustermesh-secrets", VolumeSource: v1.VolumeSource{
+						Secret: &v1.SecretVolumeSource{SecretName: "cilium-clustermesh"},
+					}}},
+				}}},
+			},
+		)
+
+		findings, err := Secrets(context.Background(), clientset, "", []string{"kube-system"})
+		Ω(err).Should(BeNil())
+		Expect(findings).To(ConsistOf(
+			report.Finding{
+				Check:    checks.SecretEnvVars,
+				Resource: "Deployment web/nginx",
+				Message: "spec.template.spec.containers[0].env[1].valueFrom.secretKeyRef: " +
+					"exposes key password of Secret db as environment variable DB_PASSWORD",
+			},
+			report.Finding{
+				Check:    checks.SecretEnvVars,
+				Resource: "Deployment web/nginx",
+				Message: "spec.template.spec.containers[0].envFrom[0].secretRef: " +
+					"exposes every key of Secret api-keys as environment variables",
+			},
+			report.Finding{
+				Check:    checks.PrivilegedSecretMounts,
+				Resource: "DaemonSet monitoring/agent",
+				Message: "spec.template.spec.volumes[0].secret: " +
+					"mounts Secret credentials into a pod breaching host-network, privileged",
+			},
+			report.Finding{
+				Check:    checks.PrivilegedSecretMounts,
+				Resource: "DaemonSet monitoring/agent",
+				Message: "spec.template.spec.volumes[1].projected.sources[1].secret: " +
+					"mounts Secret ca into a pod breaching host-network, privileged",
+			},
+		))
+	})
+})
@@ -0,0 +1,159 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package audit
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/rbac"
+	"github.com/yahoo/k8s-sec-check/report"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ServiceAccountChecks are the checks ServiceAccounts evaluates
+var ServiceAccountChecks = []checks.ID{
+	checks.DefaultServiceAccount,
+	checks.ServiceAccountTokenMount,
+	checks.LegacyTokenSecrets,
+}
+
+// legacyTokenLastUsedLabel records when the API server last saw a legacy
+// service account token in use
+const legacyTokenLastUsedLabel = "kubernetes.io/legacy-token-last-used"
+
+// ServiceAccounts audits the service accounts of namespace, or of all
+// namespaces if namespace is empty, and the workloads using them, leaving
+// out the exempt namespaces:
+//   - the default service accounts must not be bound to roles, and must not
+//     automount their token (CIS 5.1.5)
+//   - workloads must not run as the default service account (CIS 5.1.5)
+//   - service accounts and workloads must not automount a token which is
+//     granted no RBAC binding, and is thus not needed (CIS 5.1.6)
+//   - no legacy long-lived token Secrets may remain
+//
+// Bindings to the groups service accounts belong to are not counted, so a
+// token is only deemed needed if its service account is bound on its own.
+func ServiceAccounts(ctx context.Context, clientset kubernetes.Interface, namespace string,
+	exempt []string) ([]report.Finding, error) {
+	exempted := map[string]bool{}
+	for _, ns := range exempt {
+		exempted[ns] = true
+	}
+
+	snapshot, err := rbac.Load(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	serviceAccounts, err := clientset.CoreV1().ServiceAccounts(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.New("Failed to list serviceaccounts: " + err.Error())
+	}
+
+	var findings []report.Finding
+	// automount tells whether each service account mounts its token by default
+	automount := map[ref]bool{}
+	bound := map[ref]bool{}
+	for _, sa := range serviceAccounts.Items {
+		if exempted[sa.Namespace] {
+			continue
+		}
+		r := ref{"ServiceAccount", sa.Namespace, sa.Name}
+		automount[r] = sa.AutomountServiceAccountToken == nil || *sa.AutomountServiceAccountToken
+		bindings := snapshot.Bindings(rbacv1.Subject{
+			Kind: rbacv1.ServiceAccountKind, Name: sa.Name, Namespace: sa.Namespace,
+		})
+		bound[r] = len(bindings) > 0
+
+		switch {
+		case sa.Name == "default" && bound[r]:
+			findings = append(findings, report.Finding{
+				Check:    checks.DefaultServiceAccount,
+				Resource: r.String(),
+				Message:  "default service account is bound by " + strings.Join(bindings, ", "),
+			})
+		case sa.Name == "default" && automount[r]:
+			findings = append(findings, report.Finding{
+				Check:    checks.DefaultServiceAccount,
+				Resource: r.String(),
+				Message:  "default service account does not set automountServiceAccountToken to false",
+			})
+		case automount[r] && !bound[r]:
+			findings = append(findings, report.Finding{
+				Check:    checks.ServiceAccountTokenMount,
+				Resource: r.String(),
+				Message:  "automounts a token which no RBAC binding grants anything",
+			})
+		}
+	}
+
+	inv, err := listInventory(ctx, clientset, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range inv.topLevel() {
+		if exempted[w.meta.Namespace] {
+			continue
+		}
+		name := w.spec.ServiceAccountName
+		if name == "" {
+			name = "default"
+		}
+		sa := ref{"ServiceAccount", w.meta.Namespace, name}
+		owner := inv.owner(w.ref())
+		add := func(id checks.ID, message string) {
+			f := report.Finding{Check: id, Resource: w.ref().String(), Message: message}
+			if owner != w.ref() {
+				f.Owner = owner.String()
+			}
+			findings = append(findings, f)
+		}
+
+		if name == "default" {
+			add(checks.DefaultServiceAccount,
+				w.specPath.Child("serviceAccountName").String()+": runs as the default service account")
+		}
+		mounted, ok := automount[sa]
+		if !ok {
+			// service accounts missing from the list mount their token by default
+			mounted = true
+		}
+		if w.spec.AutomountServiceAccountToken != nil {
+			mounted = *w.spec.AutomountServiceAccountToken
+		}
+		if mounted && !bound[sa] {
+			add(checks.ServiceAccountTokenMount, w.specPath.Child("automountServiceAccountToken").String()+
+				": mounts the token of "+sa.String()+", which no RBAC binding grants anything")
+		}
+	}
+
+	secrets, err := clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "type=" + string(v1.SecretTypeServiceAccountToken),
+	})
+	if err != nil {
+		return nil, errors.New("Failed to list secrets: " + err.Error())
+	}
+	for _, secret := range secrets.Items {
+		// the fake clientset used in tests ignores field selectors
+		if secret.Type != v1.SecretTypeServiceAccountToken || exempted[secret.Namespace] {
+			continue
+		}
+		message := "long-lived token of " + ref{"ServiceAccount", secret.Namespace,
+			secret.Annotations[v1.ServiceAccountNameKey]}.String()
+		if lastUsed, ok := secret.Labels[legacyTokenLastUsedLabel]; ok {
+			message += ", last used " + lastUsed
+		}
+		findings = append(findings, report.Finding{
+			Check:    checks.LegacyTokenSecrets,
+			Resource: ref{"Secret", secret.Namespace, secret.Name}.String(),
+			Message:  message,
+		})
+	}
+	return findings, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package audit
+
+import (
+	"context"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("auditing service accounts", func() {
+
+	It("should report the default service accounts, unneeded tokens and legacy token Secrets", func() {
+		automount := false
+		clientset := fake.NewClientset(
+			&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "web"}},
+			&v1.ServiceAccount{
+				ObjectMeta:                   metav1.ObjectMeta{Name: "default", Namespace: "api"},
+				AutomountServiceAccountToken: &automount,
+			},
+			&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "web"}},
+			&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "idle", Namespace: "web"}},
+			// exempt, like every namespace of the cluster components
+			&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "kube-public"}},
+			&rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "web"},
+				Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "deployer"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"},
+			},
+			&rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "default-view", Namespace: "api"},
+				Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "default", Namespace: "api"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+			},
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "web"},
+				Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+					Containers: []v1.Container{{Name: "nginx", Image: "nginx"}},
+				}}},
+			},
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "ci", Namespace: "web"},
+				Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+					ServiceAccountName: "deployer",
+					Containers:         []v1.Container{{Name: "ci", Image: "ci"}},
+				}}},
+			},
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "deployer-token-x2x9",
+					Namespace:   "web",
+					Annotations: map[string]string{v1.ServiceAccountNameKey: "deployer"},
+				},
+				Type: v1.SecretTypeServiceAccountToken,
+			},
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "web"},
+				Type:       v1.SecretTypeTLS,
+			},
+		)
+
+		findings, err := ServiceAccounts(context.Background(), clientset, "", []string{"kube-public"})
+		Ω(err).Should(BeNil())
+		Expect(findings).To(ConsistOf(
+			report.Finding{
+				Check:    checks.DefaultServiceAccount,
+				Resource: "ServiceAccount api/default",
+				Message:  "default service account is bound by RoleBinding/api/default-view",
+			},
+			report.Finding{
+				Check:    checks.DefaultServiceAccount,
+				Resource: "ServiceAccount web/default",
+				Message:  "default service account does not set automountServiceAccountToken to false",
+			},
+			report.Finding{
+				Check:    checks.ServiceAccountTokenMount,
+				Resource: "ServiceAccount web/idle",
+				Message:  "automounts a token which no RBAC binding grants anything",
+			},
+			report.Finding{
+				Check:    checks.DefaultServiceAccount,
+				Resource: "Deployment web/nginx",
+				Message:  "spec.template.spec.serviceAccountName: runs as the default service account",
+			},
+			report.Finding{
+				Check:    checks.ServiceAccountTokenMount,
+				Resource: "Deployment web/nginx",
+				Message: "spec.template.spec.automountServiceAccountToken: mounts the token of " +
+					"ServiceAccount web/default, which no RBAC binding grants anything",
+			},
+			report.Finding{
+				Check:    checks.LegacyTokenSecrets,
+				Resource: "Secret web/deployer-token-x2x9",
+				Message:  "long-lived token of ServiceAccount web/deployer",
+			},
+		))
+	})
+})
@@ -0,0 +1,183 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+// Package checks defines the security checks by ID, and the rules that
+// evaluate a PodSpec against them. The live suite, the static scan and the
+// reports all refer to checks by these IDs.
+package checks
+
+import (
+	"regexp"
+	"sort"
+)
+
+// ID identifies a security check
+type ID string
+
+const (
+	// Privileged : do not admit privileged containers
+	Privileged ID = "privileged"
+	// HostPID : do not admit containers wishing to share the host process ID namespace
+	HostPID ID = "host-pid"
+	// HostIPC : do not admit containers wishing to share the host IPC namespace
+	HostIPC ID = "host-ipc"
+	// HostNetwork : do not admit containers wishing to share the host network namespace
+	HostNetwork ID = "host-network"
+	// Capabilities : do not admit containers with dangerous capabilities
+	Capabilities ID = "capabilities"
+	// HostPathVolume : do not admit containers with hostPath volumes
+	HostPathVolume ID = "host-path-volume"
+	// FlexVolume : do not admit containers with flexVolume volumes
+	FlexVolume ID = "flex-volume"
+	// Impersonation : do not allow user impersonation
+	Impersonation ID = "impersonation"
+	// HostProcess : do not admit Windows HostProcess containers
+	HostProcess ID = "host-process"
+	// HostPorts : do not admit containers binding host ports
+	HostPorts ID = "host-ports"
+	// AppArmor : do not admit containers overriding the default AppArmor profile
+	AppArmor ID = "apparmor"
+	// SELinux : do not admit containers setting a custom SELinux type, user or role
+	SELinux ID = "selinux"
+	// ProcMount : do not admit containers with an unmasked /proc mount
+	ProcMount ID = "proc-mount"
+	// Seccomp : do not admit containers without the RuntimeDefault or a Localhost seccomp profile
+	Seccomp ID = "seccomp"
+	// Sysctls : do not admit pods setting unsafe sysctls
+	Sysctls ID = "sysctls"
+	// VolumeTypes : only admit the volume types allowed by the restricted profile
+	VolumeTypes ID = "volume-types"
+	// PrivilegeEscalation : do not admit containers allowing privilege escalation
+	PrivilegeEscalation ID = "privilege-escalation"
+	// RunAsNonRoot : do not admit containers that may run as root
+	RunAsNonRoot ID = "run-as-non-root"
+	// RunAsUser : do not admit containers running as user 0
+	RunAsUser ID = "run-as-user"
+	// ReadOnlyRootFilesystem : do not admit containers with a writable root filesystem
+	ReadOnlyRootFilesystem ID = "read-only-root-filesystem"
+	// RunAsGroup : do not admit containers running as group 0
+	RunAsGroup ID = "run-as-group"
+	// FSGroup : do not admit pods with an fsGroup or supplementalGroups outside of the allowed range
+	FSGroup ID = "fs-group"
+	// EphemeralContainers : do not admit privileged ephemeral containers into running pods
+	EphemeralContainers ID = "ephemeral-containers"
+	// PodUpdate : note the updates admitted on the mutable fields of running pods
+	PodUpdate ID = "pod-update"
+	// RBACEscalation : no subject may escalate to cluster-admin-equivalent power through RBAC
+	RBACEscalation ID = "rbac-escalation"
+	// ClusterAdminBindings : only allowed subjects may be bound to cluster-admin or wildcard roles
+	ClusterAdminBindings ID = "cluster-admin-bindings"
+	// DefaultServiceAccount : the default service accounts must not be bound to roles nor used by pods
+	DefaultServiceAccount ID = "default-service-account"
+	// ServiceAccountTokenMount : service account tokens must only be mounted where necessary
+	ServiceAccountTokenMount ID = "service-account-token-mount"
+	// LegacyTokenSecrets : no long-lived service account token Secrets may remain
+	LegacyTokenSecrets ID = "legacy-token-secrets"
+	// SecretAccess : only allowed subjects may get, list or watch secrets cluster-wide
+	SecretAccess ID = "secret-access"
+	// SecretEnvVars : do not expose secrets to containers as environment variables
+	SecretEnvVars ID = "secret-env-vars"
+	// PrivilegedSecretMounts : do not mount secrets into privileged pods
+	PrivilegedSecretMounts ID = "privileged-secret-mounts"
+	// SecretReadProbe : the checking identity must not read secrets outside of its namespace
+	SecretReadProbe ID = "secret-read-probe"
+	// DefaultDenyNetworkPolicy : every namespace must have a default-deny ingress and egress NetworkPolicy
+	DefaultDenyNetworkPolicy ID = "default-deny-network-policy"
+	// NetworkPolicyCoverage : every pod must be selected by a NetworkPolicy
+	NetworkPolicyCoverage ID = "network-policy-coverage"
+	// NamespaceIsolation : connections between namespaces must be blocked
+	NamespaceIsolation ID = "namespace-isolation"
+	// NamespacePodSecurity : every namespace must enforce the baseline or restricted Pod Security level
+	NamespacePodSecurity ID = "namespace-pod-security"
+	// AdmissionWebhooks : admission webhooks must not fail open nor let requests bypass them
+	AdmissionWebhooks ID = "admission-webhooks"
+	// AdmissionPolicyActions : ValidatingAdmissionPolicies must be bound to deny requests
+	AdmissionPolicyActions ID = "admission-policy-actions"
+	// PodSecurityLevel : the Pod Security Standards level enforced on the target namespace
+	PodSecurityLevel ID = "pod-security-level"
+)
+
+// titles describes every known check
+var titles = map[ID]string{
+	Privileged:     "Do not admit privileged containers",
+	HostPID:        "Do not admit containers wishing to share the host process ID namespace",
+	HostIPC:        "Do not admit containers wishing to share the host IPC namespace",
+	HostNetwork:    "Do not admit containers wishing to share the host network namespace",
+	Capabilities:   "Do not admit containers with dangerous capabilities",
+	HostPathVolume: "Do not admit containers with hostPath volumes",
+	FlexVolume:     "Do not admit containers with flexVolume volumes",
+	Impersonation:  "Do not allow user impersonation",
+
+	HostProcess:              "Do not admit Windows HostProcess containers",
+	HostPorts:                "Do not admit containers binding host ports",
+	AppArmor:                 "Do not admit containers overriding the default AppArmor profile",
+	SELinux:                  "Do not admit containers setting a custom SELinux type, user or role",
+	ProcMount:                "Do not admit containers with an unmasked /proc mount",
+	Seccomp:                  "Do not admit containers without the RuntimeDefault or a Localhost seccomp profile",
+	Sysctls:                  "Do not admit pods setting unsafe sysctls",
+	VolumeTypes:              "Only admit the volume types allowed by the restricted profile",
+	PrivilegeEscalation:      "Do not admit containers allowing privilege escalation",
+	RunAsNonRoot:             "Do not admit containers that may run as root",
+	RunAsUser:                "Do not admit containers running as user 0",
+	ReadOnlyRootFilesystem:   "Do not admit containers with a writable root filesystem",
+	RunAsGroup:               "Do not admit containers running as group 0",
+	FSGroup:                  "Do not admit pods with an fsGroup or supplementalGroups outside of the allowed range",
+	EphemeralContainers:      "Do not admit privileged ephemeral containers into running pods",
+	PodUpdate:                "Note the updates admitted on the mutable fields of running pods",
+	RBACEscalation:           "No subject may escalate to cluster-admin-equivalent power through RBAC",
+	ClusterAdminBindings:     "Only allowed subjects may be bound to cluster-admin or wildcard roles",
+	DefaultServiceAccount:    "The default service accounts must not be bound to roles nor used by pods",
+	ServiceAccountTokenMount: "Service account tokens must only be mounted where necessary",
+	LegacyTokenSecrets:       "No long-lived service account token Secrets may remain",
+	SecretAccess:             "Only allowed subjects may get, list or watch secrets cluster-wide",
+	SecretEnvVars:            "Do not expose secrets to containers as environment variables",
+	PrivilegedSecretMounts:   "Do not mount secrets into privileged pods",
+	SecretReadProbe:          "The checking identity must not read secrets outside of its namespace",
+	DefaultDenyNetworkPolicy: "Every namespace must have a default-deny ingress and egress NetworkPolicy",
+	NetworkPolicyCoverage:    "Every pod must be selected by a NetworkPolicy",
+	NamespaceIsolation:       "Connections between namespaces must be blocked",
+	NamespacePodSecurity:     "Every namespace must enforce the baseline or restricted Pod Security level",
+	AdmissionWebhooks:        "Admission webhooks must not fail open nor let requests bypass them",
+	AdmissionPolicyActions:   "ValidatingAdmissionPolicies must be bound to deny requests",
+	PodSecurityLevel:         "Enforce the restricted Pod Security Standards level",
+}
+
+// Title returns the description of the check, or the ID itself if the
+// check is unknown
+func (id ID) Title() string {
+	if title, ok := titles[id]; ok {
+		return title
+	}
+	return string(id)
+}
+
+// Known reports whether id is a defined check
+func Known(id ID) bool {
+	_, ok := titles[id]
+	return ok
+}
+
+// All returns every known check ID in sorted order
+func All() []ID {
+	ids := make([]ID, 0, len(titles))
+	for id := range titles {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// tagPattern matches a check ID tag in a spec text, e.g. "[host-pid]"
+var tagPattern = regexp.MustCompile(`\[([a-z][a-z0-9-]*)\]`)
+
+// FromText returns the known check IDs tagged in text. Live specs tag the
+// checks they cover by putting the IDs in square brackets in their text.
+func FromText(text string) []ID {
+	var ids []ID
+	for _, m := range tagPattern.FindAllStringSubmatch(text, -1) {
+		if id := ID(m[1]); Known(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
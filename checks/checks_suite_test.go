@@ -0,0 +1,16 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package checks
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestChecks(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Checks")
+}
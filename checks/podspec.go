@@ -0,0 +1,284 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package checks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// PodSpecChecks are the checks EvaluatePodSpec evaluates
+var PodSpecChecks = []ID{
+	Privileged,
+	HostNetwork,
+	HostPID,
+	HostIPC,
+	Capabilities,
+	HostPathVolume,
+	FlexVolume,
+	PrivilegeEscalation,
+	Seccomp,
+	AppArmor,
+	SELinux,
+	HostPorts,
+	ProcMount,
+	Sysctls,
+	HostProcess,
+}
+
+// LinuxCapabilities lists every Linux capability, without the CAP_ prefix
+// used by the kernel. See capabilities(7).
+var LinuxCapabilities = []v1.Capability{
+	"AUDIT_CONTROL", "AUDIT_READ", "AUDIT_WRITE", "BLOCK_SUSPEND", "BPF",
+	"CHECKPOINT_RESTORE", "CHOWN", "DAC_OVERRIDE", "DAC_READ_SEARCH", "FOWNER",
+	"FSETID", "IPC_LOCK", "IPC_OWNER", "KILL", "LEASE", "LINUX_IMMUTABLE",
+	"MAC_ADMIN", "MAC_OVERRIDE", "MKNOD", "NET_ADMIN", "NET_BIND_SERVICE",
+	"NET_BROADCAST", "NET_RAW", "PERFMON", "SETFCAP", "SETGID", "SETPCAP",
+	"SETUID", "SYS_ADMIN", "SYS_BOOT", "SYS_CHROOT", "SYS_MODULE", "SYS_NICE",
+	"SYS_PACCT", "SYS_PTRACE", "SYS_RAWIO", "SYS_RESOURCE", "SYS_TIME",
+	"SYS_TTY_CONFIG", "SYSLOG", "WAKE_ALARM",
+}
+
+// allowedCapabilities may be added under the restricted Pod Security Standard
+var allowedCapabilities = map[v1.Capability]bool{
+	"NET_BIND_SERVICE": true,
+}
+
+// CapabilityAllowed reports whether c may be added under the restricted
+// Pod Security Standard
+func CapabilityAllowed(c v1.Capability) bool {
+	return allowedCapabilities[c]
+}
+
+// allowedVolumeTypes may be used under the restricted Pod Security Standard,
+// named after their VolumeSource fields
+var allowedVolumeTypes = map[string]bool{
+	"configMap":             true,
+	"csi":                   true,
+	"downwardAPI":           true,
+	"emptyDir":              true,
+	"ephemeral":             true,
+	"persistentVolumeClaim": true,
+	"projected":             true,
+	"secret":                true,
+}
+
+// VolumeTypeAllowed reports whether the volume type, named after its
+// VolumeSource field, may be used under the restricted Pod Security Standard
+func VolumeTypeAllowed(volumeType string) bool {
+	return allowedVolumeTypes[volumeType]
+}
+
+// allowedSELinuxTypes may be set under the baseline Pod Security Standard
+var allowedSELinuxTypes = map[string]bool{
+	"":                   true,
+	"container_t":        true,
+	"container_init_t":   true,
+	"container_kvm_t":    true,
+	"container_engine_t": true,
+}
+
+// safeSysctls may be set under the baseline Pod Security Standard
+var safeSysctls = map[string]bool{
+	"kernel.shm_rmid_forced":              true,
+	"net.ipv4.ip_local_port_range":        true,
+	"net.ipv4.ip_local_reserved_ports":    true,
+	"net.ipv4.ip_unprivileged_port_start": true,
+	"net.ipv4.ping_group_range":           true,
+	"net.ipv4.tcp_syncookies":             true,
+	"net.ipv4.tcp_keepalive_time":         true,
+	"net.ipv4.tcp_fin_timeout":            true,
+	"net.ipv4.tcp_keepalive_intvl":        true,
+	"net.ipv4.tcp_keepalive_probes":       true,
+}
+
+// SysctlSafe reports whether the sysctl may be set under the baseline Pod
+// Security Standard
+func SysctlSafe(name string) bool {
+	return safeSysctls[name]
+}
+
+// Violation is a breach of a check found in a PodSpec
+type Violation struct {
+	Check ID
+	// Field is the path of the offending field, e.g.
+	// spec.containers[0].securityContext.privileged
+	Field   *field.Path
+	Message string
+}
+
+// String formats the violation the way admission errors do
+func (v Violation) String() string {
+	return v.Field.String() + ": " + v.Message
+}
+
+// EvaluatePodSpec evaluates spec, found at fldPath, against every PodSpec
+// check and returns the violations. The AppArmor profiles set through
+// annotations are evaluated by EvaluatePodAnnotations.
+func EvaluatePodSpec(spec *v1.PodSpec, fldPath *field.Path) []Violation {
+	var violations []Violation
+	add := func(id ID, p *field.Path, format string, a ...interface{}) {
+		violations = append(violations, Violation{Check: id, Field: p, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if spec.HostNetwork {
+		add(HostNetwork, fldPath.Child("hostNetwork"), "Host network is not allowed to be used")
+	}
+	if spec.HostPID {
+		add(HostPID, fldPath.Child("hostPID"), "Host PID is not allowed to be used")
+	}
+	if spec.HostIPC {
+		add(HostIPC, fldPath.Child("hostIPC"), "Host IPC is not allowed to be used")
+	}
+	if psc := spec.SecurityContext; psc != nil {
+		p := fldPath.Child("securityContext")
+		if psc.SeccompProfile != nil && psc.SeccompProfile.Type == v1.SeccompProfileTypeUnconfined {
+			add(Seccomp, p.Child("seccompProfile", "type"), "Unconfined seccomp profile is not allowed")
+		}
+		if psc.AppArmorProfile != nil && psc.AppArmorProfile.Type == v1.AppArmorProfileTypeUnconfined {
+			add(AppArmor, p.Child("appArmorProfile", "type"), "Unconfined AppArmor profile is not allowed")
+		}
+		addSELinux(psc.SELinuxOptions, p.Child("seLinuxOptions"), add)
+		for i, sysctl := range psc.Sysctls {
+			if !safeSysctls[sysctl.Name] {
+				add(Sysctls, p.Child("sysctls").Index(i).Child("name"), "sysctl %q is not allowed", sysctl.Name)
+			}
+		}
+		if wo := psc.WindowsOptions; wo != nil && wo.HostProcess != nil && *wo.HostProcess {
+			add(HostProcess, p.Child("windowsOptions", "hostProcess"), "HostProcess containers are not allowed")
+		}
+	}
+
+	for i, volume := range spec.Volumes {
+		p := fldPath.Child("volumes").Index(i)
+		if volume.HostPath != nil {
+			add(HostPathVolume, p.Child("hostPath"), "hostPath volumes are not allowed to be used")
+		}
+		if volume.FlexVolume != nil {
+			add(FlexVolume, p.Child("flexVolume"), "flexVolume volumes are not allowed to be used")
+		}
+	}
+
+	eachContainerPort(spec, fldPath, func(port v1.ContainerPort, p *field.Path) {
+		if port.HostPort != 0 {
+			add(HostPorts, p.Child("hostPort"), "Host port %d is not allowed to be used", port.HostPort)
+		}
+	})
+
+	var podSeccomp *v1.SeccompProfile
+	if spec.SecurityContext != nil {
+		podSeccomp = spec.SecurityContext.SeccompProfile
+	}
+	eachContainer(spec, fldPath, func(sc *v1.SecurityContext, p *field.Path) {
+		p = p.Child("securityContext")
+		// the container profile overrides the pod one, an Unconfined pod
+		// profile is reported on the pod
+		if (sc == nil || sc.SeccompProfile == nil) && podSeccomp == nil {
+			add(Seccomp, p.Child("seccompProfile"), "seccomp profile must be set to RuntimeDefault or Localhost")
+		}
+		if sc == nil {
+			return
+		}
+		if sc.Privileged != nil && *sc.Privileged {
+			add(Privileged, p.Child("privileged"), "Privileged containers are not allowed")
+		}
+		if sc.AllowPrivilegeEscalation != nil && *sc.AllowPrivilegeEscalation {
+			add(PrivilegeEscalation, p.Child("allowPrivilegeEscalation"),
+				"Allowing privilege escalation for containers is not allowed")
+		}
+		if sc.Capabilities != nil {
+			for j, c := range sc.Capabilities.Add {
+				if !allowedCapabilities[c] {
+					add(Capabilities, p.Child("capabilities", "add").Index(j),
+						"capability %q may not be added", c)
+				}
+			}
+		}
+		if sc.SeccompProfile != nil && sc.SeccompProfile.Type == v1.SeccompProfileTypeUnconfined {
+			add(Seccomp, p.Child("seccompProfile", "type"), "Unconfined seccomp profile is not allowed")
+		}
+		if sc.AppArmorProfile != nil && sc.AppArmorProfile.Type == v1.AppArmorProfileTypeUnconfined {
+			add(AppArmor, p.Child("appArmorProfile", "type"), "Unconfined AppArmor profile is not allowed")
+		}
+		addSELinux(sc.SELinuxOptions, p.Child("seLinuxOptions"), add)
+		if sc.ProcMount != nil && *sc.ProcMount != v1.DefaultProcMount {
+			add(ProcMount, p.Child("procMount"), "%s procMount is not allowed", *sc.ProcMount)
+		}
+		if wo := sc.WindowsOptions; wo != nil && wo.HostProcess != nil && *wo.HostProcess {
+			add(HostProcess, p.Child("windowsOptions", "hostProcess"), "HostProcess containers are not allowed")
+		}
+	})
+	return violations
+}
+
+// EvaluatePodAnnotations evaluates the annotations of a pod or pod
+// template, found at fldPath, against the AppArmor check and returns the
+// violations
+func EvaluatePodAnnotations(annotations map[string]string, fldPath *field.Path) []Violation {
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var violations []Violation
+	for _, key := range keys {
+		if strings.HasPrefix(key, v1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix) &&
+			annotations[key] == v1.DeprecatedAppArmorBetaProfileNameUnconfined {
+			violations = append(violations, Violation{Check: AppArmor, Field: fldPath.Key(key),
+				Message: "Unconfined AppArmor profile is not allowed"})
+		}
+	}
+	return violations
+}
+
+// addSELinux adds a violation for a custom SELinux type, user or role
+func addSELinux(opts *v1.SELinuxOptions, p *field.Path,
+	add func(id ID, p *field.Path, format string, a ...interface{})) {
+	if opts == nil {
+		return
+	}
+	if !allowedSELinuxTypes[opts.Type] {
+		add(SELinux, p.Child("type"), "SELinux type %q is not allowed", opts.Type)
+	}
+	if opts.User != "" {
+		add(SELinux, p.Child("user"), "SELinux user may not be set")
+	}
+	if opts.Role != "" {
+		add(SELinux, p.Child("role"), "SELinux role may not be set")
+	}
+}
+
+// eachContainer calls fn with the security context and path of every init,
+// regular and ephemeral container in spec
+func eachContainer(spec *v1.PodSpec, fldPath *field.Path, fn func(sc *v1.SecurityContext, p *field.Path)) {
+	for i := range spec.InitContainers {
+		fn(spec.InitContainers[i].SecurityContext, fldPath.Child("initContainers").Index(i))
+	}
+	for i := range spec.Containers {
+		fn(spec.Containers[i].SecurityContext, fldPath.Child("containers").Index(i))
+	}
+	for i := range spec.EphemeralContainers {
+		fn(spec.EphemeralContainers[i].SecurityContext, fldPath.Child("ephemeralContainers").Index(i))
+	}
+}
+
+// eachContainerPort calls fn with every port, and its path, declared by the
+// init and regular containers in spec
+func eachContainerPort(spec *v1.PodSpec, fldPath *field.Path, fn func(port v1.ContainerPort, p *field.Path)) {
+	for i, c := range spec.InitContainers {
+		for j, port := range c.Ports {
+			fn(port, fldPath.Child("initContainers").Index(i).Child("ports").Index(j))
+		}
+	}
+	for i, c := range spec.Containers {
+		for j, port := range c.Ports {
+			fn(port, fldPath.Child("containers").Index(i).Child("ports").Index(j))
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package checks
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// violations returns the violations of spec as strings
+func violations(spec *v1.PodSpec) []string {
+	var found []string
+	for _, v := range EvaluatePodSpec(spec, field.NewPath("spec")) {
+		found = append(found, string(v.Check)+" "+v.String())
+	}
+	return found
+}
+
+var _ = Describe("evaluating a PodSpec", func() {
+
+	It("should find nothing in a compliant spec", func() {
+		Expect(violations(&v1.PodSpec{
+			SecurityContext: &v1.PodSecurityContext{
+				SeccompProfile: &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault},
+			},
+			Containers: []v1.Container{{Name: "nginx"}},
+		})).To(BeEmpty())
+	})
+
+	It("should require a seccomp profile on every container, unless set on the pod", func() {
+		Expect(violations(&v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "init"}},
+			Containers: []v1.Container{{
+				Name: "nginx",
+				SecurityContext: &v1.SecurityContext{
+					SeccompProfile: &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault},
+				},
+			}},
+		})).To(ConsistOf(
+			"seccomp spec.initContainers[0].securityContext.seccompProfile: " +
+				"seccomp profile must be set to RuntimeDefault or Localhost",
+		))
+	})
+
+	It("should flag the unconfined AppArmor annotation", func() {
+		var found []string
+		for _, v := range EvaluatePodAnnotations(map[string]string{
+			v1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix + "nginx": v1.DeprecatedAppArmorBetaProfileNameUnconfined,
+			v1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix + "init":  v1.DeprecatedAppArmorBetaProfileRuntimeDefault,
+			"prometheus.io/scrape": "true",
+		}, field.NewPath("metadata", "annotations")) {
+			found = append(found, string(v.Check)+" "+v.String())
+		}
+		Expect(found).To(ConsistOf(
+			"apparmor metadata.annotations[container.apparmor.security.beta.kubernetes.io/nginx]: " +
+				"Unconfined AppArmor profile is not allowed",
+		))
+	})
+
+	It("should flag kernel hardening profiles at pod and container level", func() {
+		Expect(violations(&v1.PodSpec{
+			SecurityContext: &v1.PodSecurityContext{
+				SeccompProfile: &v1.SeccompProfile{Type: v1.SeccompProfileTypeUnconfined},
+				SELinuxOptions: &v1.SELinuxOptions{Type: "container_t", User: "system_u"},
+			},
+			InitContainers: []v1.Container{{
+				Name: "init",
+				SecurityContext: &v1.SecurityContext{
+					AppArmorProfile: &v1.AppArmorProfile{Type: v1.AppArmorProfileTypeUnconfined},
+				},
+			}},
+			Containers: []v1.Container{{
+				Name: "nginx",
+				SecurityContext: &v1.SecurityContext{
+					SELinuxOptions: &v1.SELinuxOptions{Type: "spc_t"},
+				},
+			}},
+		})).To(ConsistOf(
+			"seccomp spec.securityContext.seccompProfile.type: Unconfined seccomp profile is not allowed",
+			"selinux spec.securityContext.seLinuxOptions.user: SELinux user may not be set",
+			"apparmor spec.initContainers[0].securityContext.appArmorProfile.type: "+
+				"Unconfined AppArmor profile is not allowed",
+			"selinux spec.containers[0].securityContext.seLinuxOptions.type: SELinux type \"spc_t\" is not allowed",
+		))
+	})
+
+	It("should allow adding NET_BIND_SERVICE only", func() {
+		Expect(violations(&v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "nginx",
+				SecurityContext: &v1.SecurityContext{
+					Capabilities:   &v1.Capabilities{Add: []v1.Capability{"NET_BIND_SERVICE", "NET_RAW"}},
+					SeccompProfile: &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault},
+				},
+			}},
+		})).To(ConsistOf(
+			"capabilities spec.containers[0].securityContext.capabilities.add[1]: " +
+				"capability \"NET_RAW\" may not be added",
+		))
+	})
+
+	It("should flag unmasked procMount, unsafe sysctls and hostProcess", func() {
+		unmasked := v1.UnmaskedProcMount
+		hostProcess := true
+		Expect(violations(&v1.PodSpec{
+			SecurityContext: &v1.PodSecurityContext{
+				Sysctls: []v1.Sysctl{
+					{Name: "net.ipv4.tcp_syncookies", Value: "1"},
+					{Name: "kernel.msgmax", Value: "65536"},
+				},
+				WindowsOptions: &v1.WindowsSecurityContextOptions{HostProcess: &hostProcess},
+				SeccompProfile: &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault},
+			},
+			Containers: []v1.Container{{
+				Name:            "nginx",
+				SecurityContext: &v1.SecurityContext{ProcMount: &unmasked},
+			}},
+		})).To(ConsistOf(
+			"sysctls spec.securityContext.sysctls[1].name: sysctl \"kernel.msgmax\" is not allowed",
+			"host-process spec.securityContext.windowsOptions.hostProcess: HostProcess containers are not allowed",
+			"proc-mount spec.containers[0].securityContext.procMount: Unmasked procMount is not allowed",
+		))
+	})
+})
@@ -0,0 +1,219 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+// Command k8s-sec-check runs the security checks outside of the live test
+// suite.
+//
+// Usage:
+//
+//	k8s-sec-check scan [-o text|json] [path ...]
+//	k8s-sec-check audit [-n namespace] [-o text|json]
+//	k8s-sec-check rbac [-o text|json|dot]
+//
+// scan evaluates the manifests in the given files and directories, or on
+// stdin if no path or "-" is given. audit evaluates the workloads running in
+// the cluster set by KUBECONFIG, the service accounts and tokens they use,
+// the secrets they consume, the network policies selecting them and the Pod
+// Security labels of their namespaces, in all namespaces unless one is given,
+// as well as the admission webhooks and ValidatingAdmissionPolicies.
+// rbac builds the RBAC escalation graph of the cluster, and reports the
+// subjects which can reach cluster-admin-equivalent power and the subjects
+// bound to cluster-admin or wildcard roles outside of
+// KUBE_ALLOWED_ADMIN_SUBJECTS, or writes the whole graph in the Graphviz
+// DOT language.
+// All exit with status 1 if any check failed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yahoo/k8s-sec-check/admission"
+	"github.com/yahoo/k8s-sec-check/audit"
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/rbac"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/scan"
+	"github.com/yahoo/k8s-sec-check/util"
+)
+
+const usage = `usage: k8s-sec-check <command> [flags]
+
+commands:
+  scan [-o text|json] [path ...]       check manifests from files, directories or stdin
+  audit [-n namespace] [-o text|json]  check the workloads running in the cluster
+  rbac [-o text|json|dot]              find RBAC escalation paths and admin bindings
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	var failed bool
+	switch os.Args[1] {
+	case "scan":
+		failed, err = runScan(os.Args[2:])
+	case "audit":
+		failed, err = runAudit(os.Args[2:])
+	case "rbac":
+		failed, err = runRBAC(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "k8s-sec-check: "+err.Error())
+		os.Exit(2)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runScan runs the scan command and reports whether any check failed
+func runScan(args []string) (bool, error) {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	output := fs.String("o", string(report.Text), "output format: text or json")
+	fs.Parse(args)
+	format, err := report.ParseFormat(*output)
+	if err != nil {
+		return false, err
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	var findings []report.Finding
+	for _, path := range paths {
+		var found []report.Finding
+		if path == "-" {
+			found, err = scan.Reader("<stdin>", os.Stdin)
+		} else {
+			found, err = scan.Path(path)
+		}
+		if err != nil {
+			return false, err
+		}
+		findings = append(findings, found...)
+	}
+
+	if err := report.Write(os.Stdout, format, report.ByCheck(checks.PodSpecChecks, findings)); err != nil {
+		return false, err
+	}
+	return len(findings) > 0, nil
+}
+
+// runAudit runs the audit command and reports whether any check failed
+func runAudit(args []string) (bool, error) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	namespace := fs.String("n", "", "namespace to audit, all namespaces if empty")
+	output := fs.String("o", string(report.Text), "output format: text or json")
+	fs.Parse(args)
+	format, err := report.ParseFormat(*output)
+	if err != nil {
+		return false, err
+	}
+
+	util.SetupSignalHandler()
+	clientset, _, err := client.GetClients()
+	if err != nil {
+		return false, err
+	}
+	findings, err := audit.Workloads(util.Context(), clientset, *namespace)
+	if err != nil {
+		return false, err
+	}
+	serviceAccountFindings, err := audit.ServiceAccounts(util.Context(), clientset, *namespace, util.SystemNamespaces)
+	if err != nil {
+		return false, err
+	}
+	findings = append(findings, serviceAccountFindings...)
+	secretFindings, err := audit.Secrets(util.Context(), clientset, *namespace, util.SystemNamespaces)
+	if err != nil {
+		return false, err
+	}
+	findings = append(findings, secretFindings...)
+	networkPolicyFindings, err := audit.NetworkPolicies(util.Context(), clientset, *namespace, util.SystemNamespaces)
+	if err != nil {
+		return false, err
+	}
+	findings = append(findings, networkPolicyFindings...)
+	podSecurity, err := audit.PodSecurity(util.Context(), clientset, *namespace)
+	if err != nil {
+		return false, err
+	}
+	exempt := util.PodSecurityExemptNamespaces
+	if *namespace != "" {
+		// the other exemptions are out of scope, not stale
+		exempt = nil
+		for _, ns := range util.PodSecurityExemptNamespaces {
+			if ns == *namespace {
+				exempt = append(exempt, ns)
+			}
+		}
+	}
+	findings = append(findings, audit.PodSecurityFindings(podSecurity, exempt)...)
+	webhookFindings, err := admission.Webhooks(util.Context(), clientset, util.SystemNamespaces)
+	if err != nil {
+		return false, err
+	}
+	findings = append(findings, webhookFindings...)
+	policies, err := admission.ValidatingAdmissionPolicies(util.Context(), clientset)
+	if err != nil {
+		return false, err
+	}
+	findings = append(findings, admission.ValidatingAdmissionPolicyFindings(policies)...)
+
+	ids := append(append([]checks.ID{}, checks.PodSpecChecks...), audit.ServiceAccountChecks...)
+	ids = append(append(ids, audit.SecretChecks...), audit.NetworkPolicyChecks...)
+	ids = append(ids, checks.NamespacePodSecurity, checks.AdmissionWebhooks, checks.AdmissionPolicyActions)
+	if err := report.Write(os.Stdout, format, report.ByCheck(ids, findings)); err != nil {
+		return false, err
+	}
+	return len(findings) > 0, nil
+}
+
+// runRBAC runs the rbac command and reports whether any check failed
+func runRBAC(args []string) (bool, error) {
+	fs := flag.NewFlagSet("rbac", flag.ExitOnError)
+	output := fs.String("o", string(report.Text), "output format: text, json or dot")
+	fs.Parse(args)
+	var format report.Format
+	if *output != "dot" {
+		var err error
+		if format, err = report.ParseFormat(*output); err != nil {
+			return false, err
+		}
+	}
+
+	util.SetupSignalHandler()
+	clientset, _, err := client.GetClients()
+	if err != nil {
+		return false, err
+	}
+	snapshot, err := rbac.Load(util.Context(), clientset)
+	if err != nil {
+		return false, err
+	}
+	graph := snapshot.EscalationGraph()
+	findings := rbac.EscalationFindings(graph)
+	findings = append(findings, rbac.AdminBindingFindings(snapshot.AdminBindings(), util.AllowedAdminSubjects)...)
+	allowedReaders := append(append([]string{}, util.AllowedAdminSubjects...), util.AllowedSecretReaders...)
+	findings = append(findings, rbac.SecretReaderFindings(snapshot.SecretReaders(), allowedReaders)...)
+
+	if *output == "dot" {
+		err = graph.WriteDOT(os.Stdout)
+	} else {
+		err = report.Write(os.Stdout, format, report.ByCheck([]checks.ID{checks.RBACEscalation, checks.ClusterAdminBindings,
+			checks.SecretAccess}, findings))
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(findings) > 0, nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package rbac
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// bootstrappingLabel marks the default roles and bindings the API server
+// reconciles on startup
+const bootstrappingLabel = "kubernetes.io/bootstrapping"
+
+// bootstrapBinding is a default binding: the role it binds, and the
+// subjects it binds it to, named like the escalation graph nodes
+type bootstrapBinding struct {
+	role     string
+	subjects []string
+}
+
+// bootstrapBindings are the default bindings the API server reconciles,
+// other than those of the controllers, e.g. "ClusterRoleBinding/cluster-admin"
+var bootstrapBindings = map[string]bootstrapBinding{
+	"ClusterRoleBinding/cluster-admin": {"ClusterRole/cluster-admin", []string{"Group system:masters"}},
+	"ClusterRoleBinding/system:basic-user": {"ClusterRole/system:basic-user",
+		[]string{"Group system:authenticated"}},
+	"ClusterRoleBinding/system:discovery": {"ClusterRole/system:discovery",
+		[]string{"Group system:authenticated"}},
+	"ClusterRoleBinding/system:kube-controller-manager": {"ClusterRole/system:kube-controller-manager",
+		[]string{"User system:kube-controller-manager"}},
+	"ClusterRoleBinding/system:kube-dns": {"ClusterRole/system:kube-dns",
+		[]string{"ServiceAccount kube-system/kube-dns"}},
+	"ClusterRoleBinding/system:kube-scheduler": {"ClusterRole/system:kube-scheduler",
+		[]string{"User system:kube-scheduler"}},
+	"ClusterRoleBinding/system:monitoring": {"ClusterRole/system:monitoring",
+		[]string{"Group system:monitoring"}},
+	"ClusterRoleBinding/system:node":         {"ClusterRole/system:node", nil},
+	"ClusterRoleBinding/system:node-proxier": {"ClusterRole/system:node-proxier", []string{"User system:kube-proxy"}},
+	"ClusterRoleBinding/system:public-info-viewer": {"ClusterRole/system:public-info-viewer",
+		[]string{"Group system:authenticated", "Group system:unauthenticated"}},
+	"ClusterRoleBinding/system:service-account-issuer-discovery": {
+		"ClusterRole/system:service-account-issuer-discovery", []string{"Group system:serviceaccounts"}},
+	"ClusterRoleBinding/system:volume-scheduler": {"ClusterRole/system:volume-scheduler",
+		[]string{"User system:kube-scheduler"}},
+	"RoleBinding/kube-public/system:controller:bootstrap-signer": {
+		"Role/kube-public/system:controller:bootstrap-signer", []string{"ServiceAccount kube-system/bootstrap-signer"}},
+	"RoleBinding/kube-system/system::extension-apiserver-authentication-reader": {
+		"Role/kube-system/extension-apiserver-authentication-reader",
+		[]string{"User system:kube-controller-manager", "User system:kube-scheduler"}},
+	"RoleBinding/kube-system/system::leader-locking-kube-controller-manager": {
+		"Role/kube-system/system::leader-locking-kube-controller-manager",
+		[]string{"User system:kube-controller-manager", "ServiceAccount kube-system/kube-controller-manager"}},
+	"RoleBinding/kube-system/system::leader-locking-kube-scheduler": {
+		"Role/kube-system/system::leader-locking-kube-scheduler",
+		[]string{"User system:kube-scheduler", "ServiceAccount kube-system/kube-scheduler"}},
+	"RoleBinding/kube-system/system:controller:bootstrap-signer": {
+		"Role/kube-system/system:controller:bootstrap-signer", []string{"ServiceAccount kube-system/bootstrap-signer"}},
+	"RoleBinding/kube-system/system:controller:cloud-provider": {
+		"Role/kube-system/system:controller:cloud-provider", []string{"ServiceAccount kube-system/cloud-provider"}},
+	"RoleBinding/kube-system/system:controller:token-cleaner": {
+		"Role/kube-system/system:controller:token-cleaner", []string{"ServiceAccount kube-system/token-cleaner"}},
+}
+
+// defaultBinding reports whether the binding is one of the defaults the API
+// server reconciles: labelled as such, and binding the same role to no
+// subject other than the default one does. Anyone able to create or update
+// a binding can set the label and pick the name, so neither proves anything
+// alone.
+func defaultBinding(meta metav1.ObjectMeta, binding string, role string, subjects []rbacv1.Subject) bool {
+	if meta.Labels[bootstrappingLabel] != "rbac-defaults" {
+		return false
+	}
+	defaults, ok := bootstrapBindings[binding]
+	if !ok {
+		// system:controller:<name> binds the ClusterRole of the same name to
+		// the service account of the controller
+		controller := strings.TrimPrefix(binding, "ClusterRoleBinding/system:controller:")
+		if controller == binding || !contains(controllers, controller) {
+			return false
+		}
+		defaults = bootstrapBinding{"ClusterRole/system:controller:" + controller,
+			[]string{"ServiceAccount kube-system/" + controller}}
+	}
+	if role != defaults.role {
+		return false
+	}
+	for _, subject := range subjects {
+		if !contains(defaults.subjects, nodeName(subject)) {
+			return false
+		}
+	}
+	return true
+}
+
+// AdminBinding is a subject bound to cluster-admin or to a wildcard role
+type AdminBinding struct {
+	Binding string
+	Role    string
+	// Subject is named like the escalation graph nodes, e.g. "User alice"
+	Subject string
+	Created metav1.Time
+}
+
+// AdminBindings returns every subject of the ClusterRoleBindings and
+// RoleBindings referencing cluster-admin, or a role with a rule over all
+// verbs or all resources. The default bindings reconciled by the API
+// server, e.g. cluster-admin to system:masters, are left out.
+func (s *Snapshot) AdminBindings() []AdminBinding {
+	var bindings []AdminBinding
+	add := func(meta metav1.ObjectMeta, binding string, role string, rules []rbacv1.PolicyRule,
+		subjects []rbacv1.Subject) {
+		if defaultBinding(meta, binding, role, subjects) {
+			return
+		}
+		if role != "ClusterRole/cluster-admin" && !hasWildcardRule(rules) {
+			return
+		}
+		for _, subject := range subjects {
+			bindings = append(bindings, AdminBinding{binding, role, nodeName(subject), meta.CreationTimestamp})
+		}
+	}
+	for _, b := range s.ClusterRoleBindings {
+		add(b.ObjectMeta, "ClusterRoleBinding/"+b.Name, "ClusterRole/"+b.RoleRef.Name,
+			s.clusterRoleRules(b.RoleRef.Name), b.Subjects)
+	}
+	for _, b := range s.RoleBindings {
+		role, rules := s.roleRefRules(b.Namespace, b.RoleRef)
+		add(b.ObjectMeta, "RoleBinding/"+b.Namespace+"/"+b.Name, role, rules, roleBindingSubjects(b))
+	}
+	sort.SliceStable(bindings, func(i, j int) bool { return bindings[i].Subject < bindings[j].Subject })
+	return bindings
+}
+
+// hasWildcardRule reports whether a rule grants all verbs or all resources
+func hasWildcardRule(rules []rbacv1.PolicyRule) bool {
+	for _, rule := range rules {
+		if contains(rule.Verbs, rbacv1.VerbAll) || contains(rule.Resources, rbacv1.ResourceAll) {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminBindingFindings reports every admin binding whose subject is not in
+// allowed, with the time the binding was created
+func AdminBindingFindings(bindings []AdminBinding, allowed []string) []report.Finding {
+	allowedSubjects := map[string]bool{}
+	for _, subject := range allowed {
+		allowedSubjects[subject] = true
+	}
+	var findings []report.Finding
+	for _, b := range bindings {
+		if allowedSubjects[b.Subject] {
+			continue
+		}
+		findings = append(findings, report.Finding{
+			Check:    checks.ClusterAdminBindings,
+			Resource: b.Subject,
+			Owner:    b.Binding,
+			Message:  "bound to " + b.Role + " since " + b.Created.UTC().Format(time.RFC3339),
+		})
+	}
+	return findings
+}
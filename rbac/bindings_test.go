@@ -0,0 +1,124 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package rbac
+
+import (
+	"time"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("auditing admin bindings", func() {
+
+	created := metav1.NewTime(time.Date(2019, 5, 15, 0, 21, 8, 0, time.UTC))
+	snapshot := &Snapshot{
+		Roles: []rbacv1.Role{{
+			ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "web"},
+			Rules:      []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+		}},
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "cluster-admin",
+				Labels: map[string]string{"kubernetes.io/bootstrapping": "rbac-defaults"},
+			},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:masters"}},
+			RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "admins", CreationTimestamp: created},
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.GroupKind, Name: "platform-admins"},
+				{Kind: rbacv1.UserKind, Name: "alice"},
+			},
+			RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+		}, {
+			// labelled like the defaults, but binding a user
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "system:controller:backup",
+				Labels:            map[string]string{"kubernetes.io/bootstrapping": "rbac-defaults"},
+				CreationTimestamp: created,
+			},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "mallory"}},
+			RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+		}, {
+			// labelled and named like the defaults, but binding every authenticated user
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "system:anything",
+				Labels:            map[string]string{"kubernetes.io/bootstrapping": "rbac-defaults"},
+				CreationTimestamp: created,
+			},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:authenticated"}},
+			RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+		}, {
+			// a default binding, pointed at cluster-admin
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "system:discovery",
+				Labels:            map[string]string{"kubernetes.io/bootstrapping": "rbac-defaults"},
+				CreationTimestamp: created,
+			},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:authenticated"}},
+			RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+		}, {
+			// labelled like the defaults, but not named like them
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "masters",
+				Labels:            map[string]string{"kubernetes.io/bootstrapping": "rbac-defaults"},
+				CreationTimestamp: created,
+			},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:masters"}},
+			RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+		}},
+		RoleBindings: []rbacv1.RoleBinding{{
+			ObjectMeta: metav1.ObjectMeta{Name: "owners", Namespace: "web", CreationTimestamp: created},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "ci"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "owner"},
+		}},
+	}
+
+	It("should report the subjects missing from the allow-list", func() {
+		Expect(AdminBindingFindings(snapshot.AdminBindings(), []string{"Group platform-admins"})).To(Equal([]report.Finding{
+			{
+				Check:    checks.ClusterAdminBindings,
+				Resource: "Group system:authenticated",
+				Owner:    "ClusterRoleBinding/system:anything",
+				Message:  "bound to ClusterRole/cluster-admin since 2019-05-15T00:21:08Z",
+			},
+			{
+				Check:    checks.ClusterAdminBindings,
+				Resource: "Group system:authenticated",
+				Owner:    "ClusterRoleBinding/system:discovery",
+				Message:  "bound to ClusterRole/cluster-admin since 2019-05-15T00:21:08Z",
+			},
+			{
+				Check:    checks.ClusterAdminBindings,
+				Resource: "Group system:masters",
+				Owner:    "ClusterRoleBinding/masters",
+				Message:  "bound to ClusterRole/cluster-admin since 2019-05-15T00:21:08Z",
+			},
+			{
+				Check:    checks.ClusterAdminBindings,
+				Resource: "ServiceAccount web/ci",
+				Owner:    "RoleBinding/web/owners",
+				Message:  "bound to Role/web/owner since 2019-05-15T00:21:08Z",
+			},
+			{
+				Check:    checks.ClusterAdminBindings,
+				Resource: "User alice",
+				Owner:    "ClusterRoleBinding/admins",
+				Message:  "bound to ClusterRole/cluster-admin since 2019-05-15T00:21:08Z",
+			},
+			{
+				Check:    checks.ClusterAdminBindings,
+				Resource: "User mallory",
+				Owner:    "ClusterRoleBinding/system:controller:backup",
+				Message:  "bound to ClusterRole/cluster-admin since 2019-05-15T00:21:08Z",
+			},
+		}))
+	})
+})
@@ -0,0 +1,492 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package rbac
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// ClusterAdmin is the graph node standing for cluster-admin-equivalent power
+const ClusterAdmin = "cluster-admin"
+
+const (
+	rbacGroup         = "rbac.authorization.k8s.io"
+	certificatesGroup = "certificates.k8s.io"
+	// kubeAPIServerClientSigner signs client certificates the API server
+	// trusts, including for the system:masters group
+	kubeAPIServerClientSigner = "kubernetes.io/kube-apiserver-client"
+
+	// authenticatedGroup holds every authenticated user, and
+	// unauthenticatedGroup anonymousUser, which requests without
+	// credentials authenticate as
+	authenticatedGroup   = "system:authenticated"
+	unauthenticatedGroup = "system:unauthenticated"
+	anonymousUser        = "system:anonymous"
+	// serviceAccountsGroup holds every service account, and
+	// serviceAccountsGroup:<namespace> those of the namespace
+	serviceAccountsGroup = "system:serviceaccounts"
+)
+
+// Edge is a step from a subject to more power: another subject it can act
+// as, or ClusterAdmin
+type Edge struct {
+	From string
+	To   string
+	// Power is the permission taking the step, e.g. "exec into pods"
+	Power string
+	Grant Grant
+}
+
+func (e Edge) String() string {
+	return e.From + " -[" + e.Power + " by " + e.Grant.Binding + "]-> " + e.To
+}
+
+// Flag is a risky permission held by a subject which does not lead to
+// another node on its own, e.g. a wildcard over the verbs of a resource
+type Flag struct {
+	Subject string
+	Power   string
+	Grant   Grant
+}
+
+// Graph is the escalation graph of every subject bound to a role. Nodes are
+// named after the subjects, e.g. "User alice", "Group ops" or
+// "ServiceAccount kube-system/default", and ClusterAdmin.
+type Graph struct {
+	Subjects []string
+	Edges    []Edge
+	Flags    []Flag
+
+	// outgoing indexes the edges by the node they leave, once built
+	outgoing map[string][]Edge
+}
+
+// power is a permission leading to a node when every request is granted
+type power struct {
+	name     string
+	requests []Attributes
+}
+
+// clusterPowers lead to cluster-admin-equivalent power when granted at the
+// cluster scope
+var clusterPowers = []power{
+	{"all verbs on all resources", []Attributes{{Verb: "*", APIGroup: "*", Resource: "*"}}},
+	{"escalate clusterroles", []Attributes{
+		{Verb: "escalate", APIGroup: rbacGroup, Resource: "clusterroles"},
+		{Verb: "update", APIGroup: rbacGroup, Resource: "clusterroles"},
+	}},
+	{"bind clusterroles", []Attributes{
+		{Verb: "bind", APIGroup: rbacGroup, Resource: "clusterroles"},
+		{Verb: "create", APIGroup: rbacGroup, Resource: "clusterrolebindings"},
+	}},
+	{"impersonate any user", []Attributes{{Verb: "impersonate", Resource: "users"}}},
+	{"impersonate system:masters", []Attributes{{Verb: "impersonate", Resource: "groups", Name: "system:masters"}}},
+	{"proxy to the kubelet API", []Attributes{{Verb: "get", Resource: "nodes", Subresource: "proxy"}}},
+	{"proxy to the kubelet API", []Attributes{{Verb: "create", Resource: "nodes", Subresource: "proxy"}}},
+	{"read every secret", []Attributes{{Verb: "get", Resource: "secrets"}}},
+	{"read every secret", []Attributes{{Verb: "list", Resource: "secrets"}}},
+	{"approve client certificates", []Attributes{
+		{Verb: "update", APIGroup: certificatesGroup, Resource: "certificatesigningrequests", Subresource: "approval"},
+		{Verb: "approve", APIGroup: certificatesGroup, Resource: "signers", Name: kubeAPIServerClientSigner},
+	}},
+	{"approve client certificates", []Attributes{
+		{Verb: "update", APIGroup: certificatesGroup, Resource: "certificatesigningrequests", Subresource: "approval"},
+		{Verb: "approve", APIGroup: certificatesGroup, Resource: "signers", Name: "kubernetes.io/*"},
+	}},
+}
+
+// serviceAccountPowers lead to a service account, when granted in its
+// namespace. Empty names are filled in with the service account name.
+var serviceAccountPowers = []power{
+	{"all verbs on all resources in the namespace", []Attributes{{Verb: "*", APIGroup: "*", Resource: "*"}}},
+	{"escalate roles", []Attributes{
+		{Verb: "escalate", APIGroup: rbacGroup, Resource: "roles"},
+		{Verb: "update", APIGroup: rbacGroup, Resource: "roles"},
+	}},
+	{"bind clusterroles in the namespace", []Attributes{
+		{Verb: "bind", APIGroup: rbacGroup, Resource: "clusterroles"},
+		{Verb: "create", APIGroup: rbacGroup, Resource: "rolebindings"},
+	}},
+	{"impersonate", []Attributes{{Verb: "impersonate", Resource: "serviceaccounts", Name: "-"}}},
+	{"create tokens", []Attributes{{Verb: "create", Resource: "serviceaccounts", Subresource: "token", Name: "-"}}},
+	{"exec into pods", []Attributes{{Verb: "create", Resource: "pods", Subresource: "exec"}}},
+	{"exec into pods", []Attributes{{Verb: "get", Resource: "pods", Subresource: "exec"}}},
+	{"read secrets", []Attributes{{Verb: "get", Resource: "secrets"}}},
+	{"read secrets", []Attributes{{Verb: "list", Resource: "secrets"}}},
+}
+
+// impersonationPowers lead to the user or group of the name
+var impersonationPowers = map[string]power{
+	rbacv1.UserKind:  {"impersonate", []Attributes{{Verb: "impersonate", Resource: "users", Name: "-"}}},
+	rbacv1.GroupKind: {"impersonate", []Attributes{{Verb: "impersonate", Resource: "groups", Name: "-"}}},
+}
+
+// EscalationGraph works out, for every subject bound to a role, the edges
+// to the subjects it can act as and to ClusterAdmin, and flags the
+// wildcard rules it holds. The powers of a subject are worked out once per
+// namespace from its own grants, then expanded to the subjects they reach.
+func (s *Snapshot) EscalationGraph() *Graph {
+	subjects := s.subjects()
+	g := &Graph{}
+	var namespaces []string
+	serviceAccounts := map[string][]rbacv1.Subject{}
+	targets := map[string][]rbacv1.Subject{}
+	for _, subject := range subjects {
+		g.Subjects = append(g.Subjects, nodeName(subject))
+		if subject.Kind != rbacv1.ServiceAccountKind {
+			targets[subject.Kind] = append(targets[subject.Kind], subject)
+			continue
+		}
+		if _, ok := serviceAccounts[subject.Namespace]; !ok {
+			namespaces = append(namespaces, subject.Namespace)
+		}
+		serviceAccounts[subject.Namespace] = append(serviceAccounts[subject.Namespace], subject)
+	}
+
+	// an edge between the same nodes through the same power is added once
+	added := map[string]bool{}
+	addEdge := func(e Edge) {
+		key := e.From + "\x00" + e.To + "\x00" + e.Power
+		if !added[key] {
+			added[key] = true
+			g.Edges = append(g.Edges, e)
+		}
+	}
+	bound := s.boundGrants()
+	for _, from := range subjects {
+		node := nodeName(from)
+		grants := subjectGrants(bound, from)
+		expand := func(p power, namespace string, to []rbacv1.Subject) {
+			r, ok := grantedOver(p, grants, namespace)
+			if !ok {
+				return
+			}
+			for _, target := range to {
+				if grant, ok := r.over(target.Name); ok && target != from {
+					addEdge(Edge{node, nodeName(target), p.name, grant})
+				}
+			}
+		}
+
+		for _, p := range clusterPowers {
+			if r, ok := grantedOver(p, grants, ""); ok {
+				addEdge(Edge{node, ClusterAdmin, p.name, r.grant})
+			}
+		}
+		for _, namespace := range grantedNamespaces(grants, namespaces) {
+			for _, p := range serviceAccountPowers {
+				expand(p, namespace, serviceAccounts[namespace])
+			}
+		}
+		for _, kind := range []string{rbacv1.UserKind, rbacv1.GroupKind} {
+			expand(impersonationPowers[kind], "", targets[kind])
+		}
+		g.addWildcardFlags(node, grants)
+	}
+	return g
+}
+
+// grantedNamespaces returns the namespaces any of the grants applies in:
+// all of them with a ClusterRoleBinding, or else those of the RoleBindings
+func grantedNamespaces(grants []scopedGrant, namespaces []string) []string {
+	var granted []string
+	for _, namespace := range namespaces {
+		for _, g := range grants {
+			if g.appliesIn(namespace) {
+				granted = append(granted, namespace)
+				break
+			}
+		}
+	}
+	return granted
+}
+
+// reach is the targets a power is granted over: every target through
+// grant, or, with named set, only those the rules name
+type reach struct {
+	grant Grant
+	named map[string]Grant
+}
+
+// over returns the grant of the power over the named target
+func (r reach) over(name string) (Grant, bool) {
+	if r.named == nil {
+		return r.grant, true
+	}
+	grant, ok := r.named[name]
+	return grant, ok
+}
+
+// grantedOver works out the targets the grants hold the power over, in the
+// namespace, or at the cluster scope for an empty one. Requests named "-"
+// are made over the target, and granted over the names of the rules
+// restricted to resource names; every other request must be granted
+// outright. ok is false if the power reaches no target.
+func grantedOver(p power, grants []scopedGrant, namespace string) (r reach, ok bool) {
+	for i, attrs := range p.requests {
+		attrs.Namespace = namespace
+		byName := attrs.Name == "-"
+		if byName {
+			attrs.Name = ""
+		}
+		var all *Grant
+		var named map[string]Grant
+		for _, g := range grants {
+			rule := g.Rule
+			if byName {
+				rule.ResourceNames = nil
+			}
+			if !g.appliesIn(namespace) || !RuleMatches(rule, attrs) {
+				continue
+			}
+			if !byName || len(g.Rule.ResourceNames) == 0 {
+				all = &g.Grant
+				break
+			}
+			if named == nil {
+				named = map[string]Grant{}
+			}
+			for _, name := range g.Rule.ResourceNames {
+				if _, ok := named[name]; !ok {
+					named[name] = g.Grant
+				}
+			}
+		}
+
+		switch {
+		case all != nil:
+			if i == 0 {
+				r.grant = *all
+			}
+		case len(named) == 0:
+			return reach{}, false
+		case i == 0:
+			r.named = named
+		case r.named == nil:
+			r.named = map[string]Grant{}
+			for name := range named {
+				r.named[name] = r.grant
+			}
+		default:
+			for name := range r.named {
+				if _, ok := named[name]; !ok {
+					delete(r.named, name)
+				}
+			}
+			if len(r.named) == 0 {
+				return reach{}, false
+			}
+		}
+	}
+	return r, true
+}
+
+// addWildcardFlags flags the rules granting a wildcard over verbs or
+// resources which no edge already accounts for
+func (g *Graph) addWildcardFlags(node string, grants []scopedGrant) {
+	for _, grant := range grants {
+		rule := grant.Rule
+		allVerbs := contains(rule.Verbs, rbacv1.VerbAll)
+		allResources := contains(rule.Resources, rbacv1.ResourceAll)
+		if allVerbs && allResources && contains(rule.APIGroups, rbacv1.APIGroupAll) {
+			// all verbs on all resources, an edge already
+			continue
+		}
+		if allVerbs || allResources {
+			g.Flags = append(g.Flags, Flag{node, "wildcard rule " + ruleString(rule), grant.Grant})
+		}
+	}
+}
+
+// PathToClusterAdmin returns the shortest chain of edges from the subject
+// node to ClusterAdmin, or nil if there is none
+func (g *Graph) PathToClusterAdmin(node string) []Edge {
+	// breadth-first search, keeping the edge each node was reached through
+	via := map[string]Edge{}
+	visited := map[string]bool{node: true}
+	queue := []string{node}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, e := range g.edgesFrom(current) {
+			if visited[e.To] {
+				continue
+			}
+			visited[e.To] = true
+			via[e.To] = e
+			if e.To == ClusterAdmin {
+				var path []Edge
+				for at := ClusterAdmin; at != node; at = via[at].From {
+					path = append([]Edge{via[at]}, path...)
+				}
+				return path
+			}
+			queue = append(queue, e.To)
+		}
+	}
+	return nil
+}
+
+// edgesFrom returns the edges leaving the node. They are indexed on the
+// first call, so the edges must not change after it.
+func (g *Graph) edgesFrom(node string) []Edge {
+	if g.outgoing == nil {
+		g.outgoing = map[string][]Edge{}
+		for _, e := range g.Edges {
+			g.outgoing[e.From] = append(g.outgoing[e.From], e)
+		}
+	}
+	return g.outgoing[node]
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph escalation {\n")
+	fmt.Fprintf(&b, "  %q [shape=doubleoctagon];\n", ClusterAdmin)
+	for _, node := range g.Subjects {
+		fmt.Fprintf(&b, "  %q;\n", node)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", e.From, e.To, e.Power+"\n"+e.Grant.Binding)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// subjects returns every subject bound to a role, sorted by node name
+func (s *Snapshot) subjects() []rbacv1.Subject {
+	seen := map[string]rbacv1.Subject{}
+	add := func(subject rbacv1.Subject) {
+		subject.APIGroup = ""
+		seen[nodeName(subject)] = subject
+	}
+	for _, b := range s.ClusterRoleBindings {
+		for _, subject := range b.Subjects {
+			add(subject)
+		}
+	}
+	for _, b := range s.RoleBindings {
+		for _, subject := range roleBindingSubjects(b) {
+			add(subject)
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	subjects := make([]rbacv1.Subject, len(names))
+	for i, name := range names {
+		subjects[i] = seen[name]
+	}
+	return subjects
+}
+
+// scopedGrant is a grant in the namespace of its RoleBinding, or, with no
+// namespace, a grant of a ClusterRoleBinding
+type scopedGrant struct {
+	Grant
+	namespace string
+}
+
+// appliesIn reports whether the grant applies to requests in the
+// namespace, or at the cluster scope for an empty one, as in Grants
+func (g scopedGrant) appliesIn(namespace string) bool {
+	return g.namespace == "" || g.namespace == namespace
+}
+
+// boundGrants returns every rule granted by the bindings, by the node of
+// the subject bound to it
+func (s *Snapshot) boundGrants() map[string][]scopedGrant {
+	bound := map[string][]scopedGrant{}
+	add := func(binding, namespace, role string, rules []rbacv1.PolicyRule, subjects []rbacv1.Subject) {
+		for _, subject := range subjects {
+			node := nodeName(subject)
+			for _, rule := range rules {
+				bound[node] = append(bound[node], scopedGrant{Grant{binding, role, subject, rule}, namespace})
+			}
+		}
+	}
+	for _, b := range s.ClusterRoleBindings {
+		add("ClusterRoleBinding/"+b.Name, "", "ClusterRole/"+b.RoleRef.Name,
+			s.clusterRoleRules(b.RoleRef.Name), b.Subjects)
+	}
+	for _, b := range s.RoleBindings {
+		role, rules := s.roleRefRules(b.Namespace, b.RoleRef)
+		add("RoleBinding/"+b.Namespace+"/"+b.Name, b.Namespace, role, rules, roleBindingSubjects(b))
+	}
+	return bound
+}
+
+// subjectGrants returns every rule granted to the subject itself, and to
+// the groups all of its members belong to
+func subjectGrants(bound map[string][]scopedGrant, subject rbacv1.Subject) []scopedGrant {
+	user := subjectUser(subject)
+	nodes := []string{nodeName(subject)}
+	if subject.Kind == rbacv1.ServiceAccountKind {
+		// a binding to the user name of a service account binds it too
+		nodes = append(nodes, rbacv1.UserKind+" "+user.Name)
+	}
+	for _, group := range user.Groups {
+		if node := rbacv1.GroupKind + " " + group; !contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	var grants []scopedGrant
+	for _, node := range nodes {
+		grants = append(grants, bound[node]...)
+	}
+	return grants
+}
+
+// subjectUser returns the user the subject authenticates as, with the
+// groups all of its members belong to: system:authenticated, or
+// system:unauthenticated for anonymous requests, and the service account
+// groups for service accounts
+func subjectUser(subject rbacv1.Subject) User {
+	switch subject.Kind {
+	case rbacv1.ServiceAccountKind:
+		return User{
+			Name: serviceAccountPrefix + subject.Namespace + ":" + subject.Name,
+			Groups: []string{serviceAccountsGroup, serviceAccountsGroup + ":" + subject.Namespace,
+				authenticatedGroup},
+		}
+	case rbacv1.GroupKind:
+		switch {
+		case subject.Name == unauthenticatedGroup:
+			return User{Groups: []string{unauthenticatedGroup}}
+		case strings.HasPrefix(subject.Name, serviceAccountsGroup+":"):
+			return User{Groups: []string{subject.Name, serviceAccountsGroup, authenticatedGroup}}
+		}
+		return User{Groups: []string{subject.Name, authenticatedGroup}}
+	}
+	if subject.Name == anonymousUser {
+		return User{Name: anonymousUser, Groups: []string{unauthenticatedGroup}}
+	}
+	return User{Name: subject.Name, Groups: []string{authenticatedGroup}}
+}
+
+// nodeName names the graph node of the subject
+func nodeName(subject rbacv1.Subject) string {
+	if subject.Kind == rbacv1.ServiceAccountKind {
+		return subject.Kind + " " + subject.Namespace + "/" + subject.Name
+	}
+	return subject.Kind + " " + subject.Name
+}
+
+// ruleString formats the rule the way kubectl describe does
+func ruleString(rule rbacv1.PolicyRule) string {
+	s := "[" + strings.Join(rule.Verbs, ",") + "] on [" + strings.Join(rule.Resources, ",") + "]"
+	if len(rule.APIGroups) > 0 && !(len(rule.APIGroups) == 1 && rule.APIGroups[0] == "") {
+		s += " in [" + strings.Join(rule.APIGroups, ",") + "]"
+	}
+	return s
+}
@@ -0,0 +1,183 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package rbac
+
+import (
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("building the escalation graph", func() {
+
+	snapshot := &Snapshot{
+		ClusterRoles: []rbacv1.ClusterRole{{
+			ObjectMeta: metav1.ObjectMeta{Name: "binder"},
+			Rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"bind"}, APIGroups: []string{rbacGroup}, Resources: []string{"clusterroles"}},
+				{Verbs: []string{"create"}, APIGroups: []string{rbacGroup}, Resources: []string{"clusterrolebindings"}},
+			},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "deployments-admin"},
+			Rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"*"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+			},
+		}},
+		Roles: []rbacv1.Role{{
+			ObjectMeta: metav1.ObjectMeta{Name: "debugger", Namespace: "web"},
+			Rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}},
+			},
+		}},
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "deployer", Namespace: "web"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "binder"},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "devs"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "deployments-admin"},
+		}},
+		RoleBindings: []rbacv1.RoleBinding{{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice-debug", Namespace: "web"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "debugger"},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "alice-debug", Namespace: "api"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "debugger"},
+		}},
+	}
+
+	It("should chain edges through service accounts to cluster-admin", func() {
+		g := snapshot.EscalationGraph()
+		Expect(g.Subjects).To(Equal([]string{"Group devs", "ServiceAccount web/deployer", "User alice"}))
+		Expect(FormatPath(g.PathToClusterAdmin("User alice"))).To(Equal(
+			"User alice -[exec into pods by RoleBinding/web/alice-debug]-> ServiceAccount web/deployer " +
+				"-[bind clusterroles by ClusterRoleBinding/deployer]-> cluster-admin"))
+		Expect(g.PathToClusterAdmin("Group devs")).To(BeNil())
+	})
+
+	It("should report paths and wildcard rules as findings", func() {
+		Expect(EscalationFindings(snapshot.EscalationGraph())).To(ConsistOf(
+			report.Finding{
+				Check:    checks.RBACEscalation,
+				Resource: "Group devs",
+				Owner:    "ClusterRoleBinding/devs",
+				Message:  "holds wildcard rule [*] on [deployments] in [apps] (ClusterRole/deployments-admin)",
+			},
+			report.Finding{
+				Check:    checks.RBACEscalation,
+				Resource: "ServiceAccount web/deployer",
+				Owner:    "ClusterRoleBinding/deployer",
+				Message: "reaches cluster-admin: ServiceAccount web/deployer " +
+					"-[bind clusterroles by ClusterRoleBinding/deployer]-> cluster-admin",
+			},
+			report.Finding{
+				Check:    checks.RBACEscalation,
+				Resource: "User alice",
+				Owner:    "RoleBinding/web/alice-debug",
+				Message: "reaches cluster-admin: User alice -[exec into pods by RoleBinding/web/alice-debug]-> " +
+					"ServiceAccount web/deployer -[bind clusterroles by ClusterRoleBinding/deployer]-> cluster-admin",
+			},
+		))
+	})
+
+	It("should only reach the service accounts the rules name", func() {
+		snapshot := &Snapshot{
+			Roles: []rbacv1.Role{{
+				ObjectMeta: metav1.ObjectMeta{Name: "token-minter", Namespace: "web"},
+				Rules: []rbacv1.PolicyRule{{Verbs: []string{"create"}, APIGroups: []string{""},
+					Resources: []string{"serviceaccounts/token"}, ResourceNames: []string{"frontend"}}},
+			}},
+			RoleBindings: []rbacv1.RoleBinding{{
+				ObjectMeta: metav1.ObjectMeta{Name: "minters", Namespace: "web"},
+				Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "carol"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "token-minter"},
+			}, {
+				ObjectMeta: metav1.ObjectMeta{Name: "workloads", Namespace: "web"},
+				Subjects: []rbacv1.Subject{
+					{Kind: rbacv1.ServiceAccountKind, Name: "frontend"},
+					{Kind: rbacv1.ServiceAccountKind, Name: "backend"},
+				},
+				RoleRef: rbacv1.RoleRef{Kind: "Role", Name: "token-minter"},
+			}},
+		}
+		Expect(snapshot.EscalationGraph().Edges).To(ConsistOf(
+			Edge{"User carol", "ServiceAccount web/frontend", "create tokens", Grant{
+				Binding: "RoleBinding/web/minters",
+				Role:    "Role/web/token-minter",
+				Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "carol"},
+				Rule:    snapshot.Roles[0].Rules[0],
+			}},
+			Edge{"ServiceAccount web/backend", "ServiceAccount web/frontend", "create tokens", Grant{
+				Binding: "RoleBinding/web/workloads",
+				Role:    "Role/web/token-minter",
+				Subject: rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "backend", Namespace: "web"},
+				Rule:    snapshot.Roles[0].Rules[0],
+			}},
+		))
+	})
+
+	It("should report cluster-admin granted to every authenticated user or to anonymous requests", func() {
+		snapshot := &Snapshot{
+			ClusterRoles: []rbacv1.ClusterRole{{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+				Rules: []rbacv1.PolicyRule{
+					{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+				},
+			}},
+			ClusterRoleBindings: []rbacv1.ClusterRoleBinding{{
+				ObjectMeta: metav1.ObjectMeta{Name: "everyone"},
+				Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:authenticated"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+			}, {
+				ObjectMeta: metav1.ObjectMeta{Name: "anonymous"},
+				Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "system:anonymous"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+			}},
+			RoleBindings: []rbacv1.RoleBinding{{
+				ObjectMeta: metav1.ObjectMeta{Name: "view", Namespace: "kube-system"},
+				Subjects: []rbacv1.Subject{
+					{Kind: rbacv1.ServiceAccountKind, Name: "generic-garbage-collector"},
+					{Kind: rbacv1.ServiceAccountKind, Name: "backup"},
+				},
+				RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+			}},
+		}
+		reaches := func(node string) report.Finding {
+			return report.Finding{
+				Check:    checks.RBACEscalation,
+				Resource: node,
+				Owner:    "ClusterRoleBinding/everyone",
+				Message: "reaches cluster-admin: " + node +
+					" -[all verbs on all resources by ClusterRoleBinding/everyone]-> cluster-admin",
+			}
+		}
+		Expect(EscalationFindings(snapshot.EscalationGraph())).To(ConsistOf(
+			reaches("Group system:authenticated"),
+			reaches("ServiceAccount kube-system/backup"),
+			report.Finding{
+				Check:    checks.RBACEscalation,
+				Resource: "User system:anonymous",
+				Owner:    "ClusterRoleBinding/anonymous",
+				Message: "reaches cluster-admin: User system:anonymous " +
+					"-[all verbs on all resources by ClusterRoleBinding/anonymous]-> cluster-admin",
+			},
+		))
+	})
+
+	It("should write the graph in DOT", func() {
+		var b strings.Builder
+		Ω(snapshot.EscalationGraph().WriteDOT(&b)).Should(Succeed())
+		Expect(b.String()).To(ContainSubstring(
+			`"ServiceAccount web/deployer" -> "cluster-admin" [label="bind clusterroles\nClusterRoleBinding/deployer"];`))
+	})
+})
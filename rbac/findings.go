@@ -0,0 +1,136 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package rbac
+
+import (
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+)
+
+// bootstrapIdentities are the identities Kubernetes components run as,
+// which the default bindings bind roles to. The groups every user belongs
+// to, e.g. system:authenticated, and system:anonymous are not among them.
+var bootstrapIdentities = []string{
+	"User system:kube-controller-manager",
+	"User system:kube-proxy",
+	"User system:kube-scheduler",
+	"Group system:masters",
+	"Group system:monitoring",
+	"Group system:nodes",
+	"ServiceAccount kube-system/bootstrap-signer",
+	"ServiceAccount kube-system/cloud-provider",
+	"ServiceAccount kube-system/kube-controller-manager",
+	"ServiceAccount kube-system/kube-dns",
+	"ServiceAccount kube-system/kube-scheduler",
+	"ServiceAccount kube-system/token-cleaner",
+}
+
+// controllers are the controllers of the controller manager. Each runs as
+// the kube-system service account of its name, which the default binding
+// system:controller:<name> binds to the ClusterRole of the same name.
+var controllers = []string{
+	"attachdetach-controller",
+	"certificate-controller",
+	"clusterrole-aggregation-controller",
+	"cronjob-controller",
+	"daemon-set-controller",
+	"deployment-controller",
+	"disruption-controller",
+	"endpoint-controller",
+	"endpointslice-controller",
+	"endpointslicemirroring-controller",
+	"ephemeral-volume-controller",
+	"expand-controller",
+	"generic-garbage-collector",
+	"horizontal-pod-autoscaler",
+	"job-controller",
+	"legacy-service-account-token-cleaner",
+	"namespace-controller",
+	"node-controller",
+	"persistent-volume-binder",
+	"pod-garbage-collector",
+	"pv-protection-controller",
+	"pvc-protection-controller",
+	"replicaset-controller",
+	"replication-controller",
+	"resourcequota-controller",
+	"root-ca-cert-publisher",
+	"route-controller",
+	"selinux-warning-controller",
+	"service-account-controller",
+	"service-cidrs-controller",
+	"service-controller",
+	"statefulset-controller",
+	"ttl-after-finished-controller",
+	"ttl-controller",
+	"validatingadmissionpolicy-status-controller",
+}
+
+// defaultIdentity reports whether the node is one of the identities
+// Kubernetes components run as. They stay in the graph, since reaching one
+// of them is a step to more power, but are not reported on their own.
+func defaultIdentity(node string) bool {
+	if contains(bootstrapIdentities, node) {
+		return true
+	}
+	name := strings.TrimPrefix(node, "ServiceAccount kube-system/")
+	return name != node && contains(controllers, name)
+}
+
+// FormatPath formats a chain of edges as
+// "User alice -[bind clusterroles by ClusterRoleBinding/x]-> cluster-admin"
+func FormatPath(path []Edge) string {
+	if len(path) == 0 {
+		return ""
+	}
+	s := path[0].From
+	for _, e := range path {
+		s += " -[" + e.Power + " by " + e.Grant.Binding + "]-> " + e.To
+	}
+	return s
+}
+
+// EscalationFindings reports, for every subject other than the default
+// identities, its path to cluster-admin-equivalent power if it has one,
+// or else each edge it has to another subject, and its wildcard rules
+func EscalationFindings(g *Graph) []report.Finding {
+	flags := map[string][]Flag{}
+	for _, f := range g.Flags {
+		flags[f.Subject] = append(flags[f.Subject], f)
+	}
+	var findings []report.Finding
+	for _, node := range g.Subjects {
+		if defaultIdentity(node) {
+			continue
+		}
+		if path := g.PathToClusterAdmin(node); path != nil {
+			findings = append(findings, report.Finding{
+				Check:    checks.RBACEscalation,
+				Resource: node,
+				Owner:    path[0].Grant.Binding,
+				Message:  "reaches cluster-admin: " + FormatPath(path),
+			})
+		} else {
+			for _, e := range g.edgesFrom(node) {
+				findings = append(findings, report.Finding{
+					Check:    checks.RBACEscalation,
+					Resource: node,
+					Owner:    e.Grant.Binding,
+					Message:  "can act as " + e.To + " through " + e.Power + " (" + e.Grant.Role + ")",
+				})
+			}
+		}
+		for _, f := range flags[node] {
+			findings = append(findings, report.Finding{
+				Check:    checks.RBACEscalation,
+				Resource: node,
+				Owner:    f.Grant.Binding,
+				Message:  "holds " + f.Power + " (" + f.Grant.Role + ")",
+			})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,49 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package rbac
+
+import (
+	"sort"
+
+	"k8s.io/client-go/rest"
+)
+
+// authenticationGroup is the API group of the uids and userextras
+// impersonation resources
+const authenticationGroup = "authentication.k8s.io"
+
+// ImpersonationAttributes returns the impersonate requests the API server
+// authorizes for the impersonation: one for the user or service account,
+// one per group, one for the uid and one per extra value.
+// See https://kubernetes.io/docs/reference/access-authn-authz/authentication/#user-impersonation
+func ImpersonationAttributes(impersonate rest.ImpersonationConfig) []Attributes {
+	var attrs []Attributes
+	if impersonate.UserName != "" {
+		if namespace, name, ok := ServiceAccountUser(impersonate.UserName); ok {
+			attrs = append(attrs, Attributes{Verb: "impersonate", Resource: "serviceaccounts",
+				Name: name, Namespace: namespace})
+		} else {
+			attrs = append(attrs, Attributes{Verb: "impersonate", Resource: "users", Name: impersonate.UserName})
+		}
+	}
+	for _, group := range impersonate.Groups {
+		attrs = append(attrs, Attributes{Verb: "impersonate", Resource: "groups", Name: group})
+	}
+	if impersonate.UID != "" {
+		attrs = append(attrs, Attributes{Verb: "impersonate", APIGroup: authenticationGroup,
+			Resource: "uids", Name: impersonate.UID})
+	}
+	keys := make([]string, 0, len(impersonate.Extra))
+	for key := range impersonate.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range impersonate.Extra[key] {
+			attrs = append(attrs, Attributes{Verb: "impersonate", APIGroup: authenticationGroup,
+				Resource: "userextras", Subresource: key, Name: value})
+		}
+	}
+	return attrs
+}
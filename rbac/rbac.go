@@ -0,0 +1,281 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+// Package rbac reads the RBAC roles and bindings of the cluster, and works
+// out which bindings grant a subject a given request. Checks use it to name
+// the binding behind each permitted path, so it can be removed.
+package rbac
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// serviceAccountPrefix prefixes the user name of service accounts
+const serviceAccountPrefix = "system:serviceaccount:"
+
+// Attributes describe a resource request, the way the RBAC authorizer sees
+// it. Namespace is empty for cluster-scoped resources.
+type Attributes struct {
+	Verb        string
+	APIGroup    string
+	Resource    string
+	Subresource string
+	Name        string
+	Namespace   string
+}
+
+func (a Attributes) String() string {
+	s := a.Verb + " " + a.Resource
+	if a.Subresource != "" {
+		s += "/" + a.Subresource
+	}
+	if a.APIGroup != "" {
+		s += "." + a.APIGroup
+	}
+	if a.Name != "" {
+		s += " " + a.Name
+	}
+	if a.Namespace != "" {
+		s += " in " + a.Namespace
+	}
+	return s
+}
+
+// User is an authenticated user, with the groups it belongs to
+type User struct {
+	Name   string
+	Groups []string
+}
+
+// Grant is a binding granting a subject a role whose rule matches a request
+type Grant struct {
+	// Binding is e.g. "ClusterRoleBinding/admins" or "RoleBinding/web/editors"
+	Binding string
+	// Role is e.g. "ClusterRole/cluster-admin" or "Role/web/editor"
+	Role    string
+	Subject rbacv1.Subject
+	Rule    rbacv1.PolicyRule
+}
+
+func (g Grant) String() string {
+	return g.Binding + " (" + g.Role + ")"
+}
+
+// Snapshot holds every role and binding of the cluster
+type Snapshot struct {
+	ClusterRoles        []rbacv1.ClusterRole
+	Roles               []rbacv1.Role
+	ClusterRoleBindings []rbacv1.ClusterRoleBinding
+	RoleBindings        []rbacv1.RoleBinding
+}
+
+// Load lists the roles and bindings of all namespaces
+func Load(ctx context.Context, clientset kubernetes.Interface) (*Snapshot, error) {
+	api := clientset.RbacV1()
+	clusterRoles, err := api.ClusterRoles().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.New("Failed to list clusterroles: " + err.Error())
+	}
+	roles, err := api.Roles("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.New("Failed to list roles: " + err.Error())
+	}
+	clusterRoleBindings, err := api.ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.New("Failed to list clusterrolebindings: " + err.Error())
+	}
+	roleBindings, err := api.RoleBindings("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.New("Failed to list rolebindings: " + err.Error())
+	}
+	return &Snapshot{
+		ClusterRoles:        clusterRoles.Items,
+		Roles:               roles.Items,
+		ClusterRoleBindings: clusterRoleBindings.Items,
+		RoleBindings:        roleBindings.Items,
+	}, nil
+}
+
+// Self returns the user the clientset authenticates as
+func Self(ctx context.Context, clientset kubernetes.Interface) (User, error) {
+	review, err := clientset.AuthenticationV1().SelfSubjectReviews().
+		Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil {
+		return User{}, errors.New("Failed to review self subject: " + err.Error())
+	}
+	return User{Name: review.Status.UserInfo.Username, Groups: review.Status.UserInfo.Groups}, nil
+}
+
+// Grants returns every grant of a rule matching the request to the user
+func (s *Snapshot) Grants(user User, attrs Attributes) []Grant {
+	var grants []Grant
+	for _, b := range s.ClusterRoleBindings {
+		subject, ok := boundSubject(b.Subjects, user)
+		if !ok {
+			continue
+		}
+		role := "ClusterRole/" + b.RoleRef.Name
+		for _, rule := range s.clusterRoleRules(b.RoleRef.Name) {
+			if RuleMatches(rule, attrs) {
+				grants = append(grants, Grant{"ClusterRoleBinding/" + b.Name, role, subject, rule})
+			}
+		}
+	}
+	for _, b := range s.RoleBindings {
+		// a RoleBinding only grants access within its own namespace
+		if attrs.Namespace != b.Namespace {
+			continue
+		}
+		subject, ok := boundSubject(roleBindingSubjects(b), user)
+		if !ok {
+			continue
+		}
+		role, rules := s.roleRefRules(b.Namespace, b.RoleRef)
+		for _, rule := range rules {
+			if RuleMatches(rule, attrs) {
+				grants = append(grants, Grant{"RoleBinding/" + b.Namespace + "/" + b.Name, role, subject, rule})
+			}
+		}
+	}
+	return grants
+}
+
+// Bindings returns the bindings naming the subject itself, e.g.
+// "RoleBinding/web/deployer", leaving out those binding a group it belongs to
+func (s *Snapshot) Bindings(subject rbacv1.Subject) []string {
+	user := User{Name: subject.Name}
+	if subject.Kind == rbacv1.ServiceAccountKind {
+		user.Name = serviceAccountPrefix + subject.Namespace + ":" + subject.Name
+	}
+	var bindings []string
+	for _, b := range s.ClusterRoleBindings {
+		if bound, ok := boundSubject(b.Subjects, user); ok && bound.Kind == subject.Kind {
+			bindings = append(bindings, "ClusterRoleBinding/"+b.Name)
+		}
+	}
+	for _, b := range s.RoleBindings {
+		if bound, ok := boundSubject(roleBindingSubjects(b), user); ok && bound.Kind == subject.Kind {
+			bindings = append(bindings, "RoleBinding/"+b.Namespace+"/"+b.Name)
+		}
+	}
+	return bindings
+}
+
+// clusterRoleRules returns the rules of the named ClusterRole
+func (s *Snapshot) clusterRoleRules(name string) []rbacv1.PolicyRule {
+	for _, r := range s.ClusterRoles {
+		if r.Name == name {
+			return r.Rules
+		}
+	}
+	return nil
+}
+
+// roleRefRules returns the name and the rules of the role a RoleBinding in
+// namespace refers to, which may be a Role or a ClusterRole
+func (s *Snapshot) roleRefRules(namespace string, ref rbacv1.RoleRef) (string, []rbacv1.PolicyRule) {
+	if ref.Kind == "ClusterRole" {
+		return "ClusterRole/" + ref.Name, s.clusterRoleRules(ref.Name)
+	}
+	for _, r := range s.Roles {
+		if r.Namespace == namespace && r.Name == ref.Name {
+			return "Role/" + namespace + "/" + ref.Name, r.Rules
+		}
+	}
+	return "Role/" + namespace + "/" + ref.Name, nil
+}
+
+// roleBindingSubjects returns the subjects of the RoleBinding, with the
+// service accounts defaulting to its namespace as the authorizer does
+func roleBindingSubjects(b rbacv1.RoleBinding) []rbacv1.Subject {
+	subjects := make([]rbacv1.Subject, len(b.Subjects))
+	for i, subject := range b.Subjects {
+		if subject.Kind == rbacv1.ServiceAccountKind && subject.Namespace == "" {
+			subject.Namespace = b.Namespace
+		}
+		subjects[i] = subject
+	}
+	return subjects
+}
+
+// boundSubject returns the subject of the binding the user matches, if any
+func boundSubject(subjects []rbacv1.Subject, user User) (rbacv1.Subject, bool) {
+	for _, s := range subjects {
+		switch s.Kind {
+		case rbacv1.UserKind:
+			if s.Name == user.Name {
+				return s, true
+			}
+		case rbacv1.GroupKind:
+			for _, g := range user.Groups {
+				if s.Name == g {
+					return s, true
+				}
+			}
+		case rbacv1.ServiceAccountKind:
+			if serviceAccountPrefix+s.Namespace+":"+s.Name == user.Name {
+				return s, true
+			}
+		}
+	}
+	return rbacv1.Subject{}, false
+}
+
+// RuleMatches reports whether the rule allows the request, with the same
+// wildcard semantics as the RBAC authorizer
+func RuleMatches(rule rbacv1.PolicyRule, attrs Attributes) bool {
+	return matches(rule.Verbs, attrs.Verb) &&
+		matches(rule.APIGroups, attrs.APIGroup) &&
+		resourceMatches(rule.Resources, attrs.Resource, attrs.Subresource) &&
+		(len(rule.ResourceNames) == 0 || contains(rule.ResourceNames, attrs.Name))
+}
+
+// matches reports whether values hold the wildcard or value
+func matches(values []string, value string) bool {
+	return contains(values, rbacv1.VerbAll) || contains(values, value)
+}
+
+// resourceMatches reports whether resources hold the wildcard, the
+// resource with its subresource, or a wildcard over the subresource
+func resourceMatches(resources []string, resource string, subresource string) bool {
+	combined := resource
+	if subresource != "" {
+		combined += "/" + subresource
+	}
+	for _, r := range resources {
+		if r == rbacv1.ResourceAll || r == combined ||
+			(subresource != "" && r == "*/"+subresource) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceAccountUser splits the user name of a service account into its
+// namespace and name
+func ServiceAccountUser(user string) (namespace string, name string, ok bool) {
+	if !strings.HasPrefix(user, serviceAccountPrefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(user, serviceAccountPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package rbac
+
+import (
+	"context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// grantNames returns the grants as strings
+func grantNames(grants []Grant) []string {
+	var names []string
+	for _, g := range grants {
+		names = append(names, g.String())
+	}
+	return names
+}
+
+var _ = Describe("finding the grants of a request", func() {
+
+	var snapshot *Snapshot
+
+	BeforeEach(func() {
+		clientset := fake.NewClientset(
+			&rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "impersonator"},
+				Rules: []rbacv1.PolicyRule{{
+					Verbs:         []string{"impersonate"},
+					APIGroups:     []string{""},
+					Resources:     []string{"users", "groups"},
+					ResourceNames: []string{"alice", "developers"},
+				}},
+			},
+			&rbacv1.ClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "ops-impersonate"},
+				Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "ops"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "impersonator"},
+			},
+			&rbacv1.Role{
+				ObjectMeta: metav1.ObjectMeta{Name: "sa-impersonator", Namespace: "web"},
+				Rules: []rbacv1.PolicyRule{{
+					Verbs:     []string{"*"},
+					APIGroups: []string{"*"},
+					Resources: []string{"serviceaccounts"},
+				}},
+			},
+			&rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "web"},
+				Subjects: []rbacv1.Subject{{
+					Kind: rbacv1.ServiceAccountKind, Name: "deployer", Namespace: "ci",
+				}},
+				RoleRef: rbacv1.RoleRef{Kind: "Role", Name: "sa-impersonator"},
+			},
+		)
+		var err error
+		snapshot, err = Load(context.Background(), clientset)
+		Ω(err).Should(BeNil())
+	})
+
+	It("should match groups and resource names", func() {
+		ops := User{Name: "bob", Groups: []string{"ops"}}
+		Expect(grantNames(snapshot.Grants(ops, Attributes{Verb: "impersonate", Resource: "users", Name: "alice"}))).
+			To(ConsistOf("ClusterRoleBinding/ops-impersonate (ClusterRole/impersonator)"))
+		Expect(snapshot.Grants(ops, Attributes{Verb: "impersonate", Resource: "users", Name: "admin"})).To(BeEmpty())
+	})
+
+	It("should only apply RoleBindings in their namespace", func() {
+		deployer := User{Name: "system:serviceaccount:ci:deployer"}
+		attrs := ImpersonationAttributes(rest.ImpersonationConfig{UserName: "system:serviceaccount:web:default"})
+		Expect(attrs).To(ConsistOf(Attributes{
+			Verb: "impersonate", Resource: "serviceaccounts", Name: "default", Namespace: "web",
+		}))
+		Expect(grantNames(snapshot.Grants(deployer, attrs[0]))).
+			To(ConsistOf("RoleBinding/web/deployer (Role/web/sa-impersonator)"))
+
+		attrs = ImpersonationAttributes(rest.ImpersonationConfig{UserName: "system:serviceaccount:kube-system:default"})
+		Expect(snapshot.Grants(deployer, attrs[0])).To(BeEmpty())
+	})
+
+	It("should map uids and extras to the authentication.k8s.io resources", func() {
+		Expect(ImpersonationAttributes(rest.ImpersonationConfig{
+			UserName: "alice",
+			UID:      "1234",
+			Extra:    map[string][]string{"scopes": {"view"}},
+		})).To(Equal([]Attributes{
+			{Verb: "impersonate", Resource: "users", Name: "alice"},
+			{Verb: "impersonate", APIGroup: "authentication.k8s.io", Resource: "uids", Name: "1234"},
+			{Verb: "impersonate", APIGroup: "authentication.k8s.io", Resource: "userextras",
+				Subresource: "scopes", Name: "view"},
+		}))
+	})
+})
@@ -0,0 +1,79 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package rbac
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+)
+
+// secretReadVerbs are the verbs that disclose the content of secrets
+var secretReadVerbs = []string{"get", "list", "watch"}
+
+// SecretReader is a subject a ClusterRoleBinding lets read every secret
+type SecretReader struct {
+	Binding string
+	Role    string
+	// Subject is named like the escalation graph nodes, e.g. "User alice"
+	Subject string
+	// Verbs are the verbs of secretReadVerbs the role grants
+	Verbs []string
+}
+
+// SecretReaders returns every subject of the ClusterRoleBindings whose role
+// grants get, list or watch on all secrets (CIS 5.1.2). Rules restricted to
+// resource names are left out, as are the default bindings reconciled by
+// the API server, e.g. those of the controller manager.
+func (s *Snapshot) SecretReaders() []SecretReader {
+	var readers []SecretReader
+	for _, b := range s.ClusterRoleBindings {
+		if defaultBinding(b.ObjectMeta, "ClusterRoleBinding/"+b.Name, "ClusterRole/"+b.RoleRef.Name, b.Subjects) {
+			continue
+		}
+		var verbs []string
+		for _, verb := range secretReadVerbs {
+			for _, rule := range s.clusterRoleRules(b.RoleRef.Name) {
+				if len(rule.ResourceNames) == 0 &&
+					RuleMatches(rule, Attributes{Verb: verb, Resource: "secrets"}) {
+					verbs = append(verbs, verb)
+					break
+				}
+			}
+		}
+		if len(verbs) == 0 {
+			continue
+		}
+		for _, subject := range b.Subjects {
+			readers = append(readers, SecretReader{"ClusterRoleBinding/" + b.Name,
+				"ClusterRole/" + b.RoleRef.Name, nodeName(subject), verbs})
+		}
+	}
+	sort.SliceStable(readers, func(i, j int) bool { return readers[i].Subject < readers[j].Subject })
+	return readers
+}
+
+// SecretReaderFindings reports every secret reader whose subject is not in
+// allowed
+func SecretReaderFindings(readers []SecretReader, allowed []string) []report.Finding {
+	allowedSubjects := map[string]bool{}
+	for _, subject := range allowed {
+		allowedSubjects[subject] = true
+	}
+	var findings []report.Finding
+	for _, r := range readers {
+		if allowedSubjects[r.Subject] {
+			continue
+		}
+		findings = append(findings, report.Finding{
+			Check:    checks.SecretAccess,
+			Resource: r.Subject,
+			Owner:    r.Binding,
+			Message:  "can " + strings.Join(r.Verbs, ", ") + " secrets in all namespaces through " + r.Role,
+		})
+	}
+	return findings
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package rbac
+
+import (
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("auditing secret readers", func() {
+
+	snapshot := &Snapshot{
+		ClusterRoles: []rbacv1.ClusterRole{{
+			ObjectMeta: metav1.ObjectMeta{Name: "auditor"},
+			Rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+				{Verbs: []string{"watch"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+			},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "ingress"},
+			Rules: []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""},
+				Resources: []string{"secrets"}, ResourceNames: []string{"tls"}}},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "system:controller:generic-garbage-collector"},
+			Rules: []rbacv1.PolicyRule{{Verbs: []string{"get", "list", "watch", "patch", "update", "delete"},
+				APIGroups: []string{"*"}, Resources: []string{"*"}}},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "vault"},
+			Rules:      []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+		}},
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "system:controller:generic-garbage-collector",
+				Labels: map[string]string{"kubernetes.io/bootstrapping": "rbac-defaults"},
+			},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "generic-garbage-collector",
+				Namespace: "kube-system"}},
+			RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "system:controller:generic-garbage-collector"},
+		}, {
+			// labelled and named like a default, but binding every authenticated user
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "system:secret-readers",
+				Labels: map[string]string{"kubernetes.io/bootstrapping": "rbac-defaults"},
+			},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:authenticated"}},
+			RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "vault"},
+		}, {
+			// labelled like the defaults, but binding a user
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "system:controller:secret-sync",
+				Labels: map[string]string{"kubernetes.io/bootstrapping": "rbac-defaults"},
+			},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "mallory"}},
+			RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "vault"},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "auditors"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "auditor"},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "ingress"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "ingress", Namespace: "ingress"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "ingress"},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "vault"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "vault", Namespace: "vault"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "vault"},
+		}},
+		RoleBindings: []rbacv1.RoleBinding{{
+			ObjectMeta: metav1.ObjectMeta{Name: "vault", Namespace: "web"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "bob"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "vault"},
+		}},
+	}
+
+	It("should report the subjects reading secrets in all namespaces missing from the allow-list", func() {
+		Expect(SecretReaderFindings(snapshot.SecretReaders(), []string{"ServiceAccount vault/vault"})).To(Equal([]report.Finding{{
+			Check:    checks.SecretAccess,
+			Resource: "Group system:authenticated",
+			Owner:    "ClusterRoleBinding/system:secret-readers",
+			Message:  "can get, list, watch secrets in all namespaces through ClusterRole/vault",
+		}, {
+			Check:    checks.SecretAccess,
+			Resource: "User alice",
+			Owner:    "ClusterRoleBinding/auditors",
+			Message:  "can get, list secrets in all namespaces through ClusterRole/auditor",
+		}, {
+			Check:    checks.SecretAccess,
+			Resource: "User mallory",
+			Owner:    "ClusterRoleBinding/system:controller:secret-sync",
+			Message:  "can get, list, watch secrets in all namespaces through ClusterRole/vault",
+		}}))
+	})
+})
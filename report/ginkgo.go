@@ -0,0 +1,138 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package report
+
+import (
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	"github.com/yahoo/k8s-sec-check/checks"
+)
+
+// SpecReporter is a Ginkgo reporter that turns every spec into a Result
+// and writes the report when the suite ends
+type SpecReporter struct {
+	out         io.Writer
+	format      Format
+	interrupted func() bool
+
+	// Ginkgo reports the end of an interrupted suite from its signal
+	// handler, concurrently with the spec that is still running
+	mu      sync.Mutex
+	running *types.SpecSummary
+	started time.Time
+	results []Result
+}
+
+// NewSpecReporter returns a SpecReporter writing to out in the given format.
+// interrupted tells whether the run was cancelled, so failures it caused are
+// not reported as findings.
+func NewSpecReporter(out io.Writer, format Format, interrupted func() bool) *SpecReporter {
+	return &SpecReporter{out: out, format: format, interrupted: interrupted}
+}
+
+// Results returns the results collected so far
+func (r *SpecReporter) Results() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.results
+}
+
+// SpecSuiteWillBegin implements ginkgo reporters.Reporter
+func (r *SpecReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+// BeforeSuiteDidRun implements ginkgo reporters.Reporter. A failed
+// BeforeSuite means no check ran, so it is reported on its own.
+func (r *SpecReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if setupSummary.State.IsFailure() {
+		r.results = append(r.results, Result{
+			Name:     "BeforeSuite",
+			Status:   Failed,
+			Duration: setupSummary.RunTime,
+			Message:  setupSummary.Failure.Message,
+		})
+	}
+}
+
+// SpecWillRun implements ginkgo reporters.Reporter
+func (r *SpecReporter) SpecWillRun(specSummary *types.SpecSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running = specSummary
+	r.started = time.Now()
+	// drop anything recorded outside of a spec
+	drain()
+}
+
+// SpecDidComplete implements ginkgo reporters.Reporter
+func (r *SpecReporter) SpecDidComplete(specSummary *types.SpecSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running = nil
+	name := specName(specSummary)
+	result := Result{
+		Name:     name,
+		Checks:   checks.FromText(name),
+		Duration: specSummary.RunTime,
+	}
+	result.Findings, result.Notes = drain()
+	switch {
+	case specSummary.Passed():
+		result.Status = Passed
+	case specSummary.Skipped(), specSummary.Pending():
+		result.Status = Skipped
+	case r.interrupted():
+		result.Status = Interrupted
+		result.Message = specSummary.Failure.Message
+	default:
+		result.Status = Failed
+		result.Message = specSummary.Failure.Message
+	}
+	r.results = append(r.results, result)
+}
+
+// AfterSuiteDidRun implements ginkgo reporters.Reporter
+func (r *SpecReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+// SpecSuiteDidEnd implements ginkgo reporters.Reporter. When Ginkgo is
+// interrupted it ends the suite without completing the running spec, so
+// that spec is recorded as interrupted here.
+func (r *SpecReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running != nil {
+		name := specName(r.running)
+		findings, notes := drain()
+		r.results = append(r.results, Result{
+			Name:     name,
+			Checks:   checks.FromText(name),
+			Status:   Interrupted,
+			Duration: time.Since(r.started),
+			Findings: findings,
+			Notes:    notes,
+		})
+		r.running = nil
+	}
+	if err := Write(r.out, r.format, r.results); err != nil {
+		log.Println("Failed to write report: " + err.Error())
+	}
+}
+
+// specName joins the spec texts, leaving out Ginkgo's top level container
+func specName(specSummary *types.SpecSummary) string {
+	texts := specSummary.ComponentTexts
+	if len(texts) > 0 {
+		texts = texts[1:]
+	}
+	return strings.Join(texts, " ")
+}
@@ -0,0 +1,41 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package report
+
+import (
+	"fmt"
+	"sync"
+)
+
+// pending holds what the running spec recorded, until SpecReporter attaches
+// it to the spec's result
+var pending struct {
+	sync.Mutex
+	findings []Finding
+	notes    []string
+}
+
+// Record attaches a finding to the result of the running spec
+func Record(f Finding) {
+	pending.Lock()
+	defer pending.Unlock()
+	pending.findings = append(pending.findings, f)
+}
+
+// Note attaches a line of information, e.g. a verdict that is not a breach,
+// to the result of the running spec
+func Note(format string, a ...interface{}) {
+	pending.Lock()
+	defer pending.Unlock()
+	pending.notes = append(pending.notes, fmt.Sprintf(format, a...))
+}
+
+// drain returns and clears what was recorded since the last call
+func drain() ([]Finding, []string) {
+	pending.Lock()
+	defer pending.Unlock()
+	findings, notes := pending.findings, pending.notes
+	pending.findings, pending.notes = nil, nil
+	return findings, notes
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+// Package report collects the outcome of every check and writes the
+// summary once the run is over.
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+)
+
+// Status is the outcome of a single check
+type Status string
+
+const (
+	// Passed means the cluster behaved as the check expects
+	Passed Status = "passed"
+	// Failed means the check found the cluster in breach of the guideline
+	Failed Status = "failed"
+	// Skipped means the check did not run
+	Skipped Status = "skipped"
+	// Interrupted means the check was stopped by a signal before it could finish
+	Interrupted Status = "interrupted"
+)
+
+// Format is an output format of the report
+type Format string
+
+const (
+	// Text writes a table meant to be read in a terminal
+	Text Format = "text"
+	// JSON writes the results as a JSON array
+	JSON Format = "json"
+)
+
+// ParseFormat returns the Format named s
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case Text, JSON:
+		return f, nil
+	}
+	return "", errors.New("unknown report format: " + s)
+}
+
+// Finding is a single breach of a check by a resource
+type Finding struct {
+	Check checks.ID `json:"check"`
+	// Resource names the offending object, e.g. "Deployment ns/name"
+	Resource string `json:"resource"`
+	// Owner names the top level object controlling Resource, if any
+	Owner string `json:"owner,omitempty"`
+	// Location points at the offending field, e.g. "deploy.yaml:12"
+	Location string `json:"location,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Result represents the outcome of one check
+type Result struct {
+	Name     string        `json:"name"`
+	Checks   []checks.ID   `json:"checks,omitempty"`
+	Status   Status        `json:"status"`
+	Duration time.Duration `json:"duration"`
+	Message  string        `json:"message,omitempty"`
+	Findings []Finding     `json:"findings,omitempty"`
+	Notes    []string      `json:"notes,omitempty"`
+}
+
+// ByCheck turns findings into one Result per check in ids. A check is
+// failed if it has any finding, and passed otherwise.
+func ByCheck(ids []checks.ID, findings []Finding) []Result {
+	results := make([]Result, 0, len(ids))
+	for _, id := range ids {
+		result := Result{
+			Name:   id.Title(),
+			Checks: []checks.ID{id},
+			Status: Passed,
+		}
+		for _, f := range findings {
+			if f.Check == id {
+				result.Findings = append(result.Findings, f)
+			}
+		}
+		if len(result.Findings) > 0 {
+			result.Status = Failed
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Write writes results to w in the given format
+func Write(w io.Writer, format Format, results []Result) error {
+	if format == JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(results)
+	}
+	return writeText(w, results)
+}
+
+// writeText writes results as a table, then the findings of every result,
+// followed by a count per status
+func writeText(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tID\tCHECK\tDURATION")
+	counts := map[Status]int{}
+	for _, r := range results {
+		counts[r.Status]++
+		ids := make([]string, len(r.Checks))
+		for i, id := range r.Checks {
+			ids[i] = string(id)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Status, strings.Join(ids, ","), r.Name,
+			r.Duration.Round(time.Second))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if len(r.Findings) == 0 && len(r.Notes) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", r.Name)
+		for _, note := range r.Notes {
+			fmt.Fprintf(w, "  %s\n", note)
+		}
+		for _, f := range r.Findings {
+			location := f.Resource
+			if f.Location != "" {
+				location = f.Location + " " + location
+			}
+			if f.Owner != "" {
+				location += " (owner: " + f.Owner + ")"
+			}
+			fmt.Fprintf(w, "  %s [%s] %s\n", location, f.Check, f.Message)
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d passed, %d failed, %d skipped, %d interrupted\n",
+		counts[Passed], counts[Failed], counts[Skipped], counts[Interrupted])
+	return err
+}
@@ -0,0 +1,248 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// podSpecPaths maps a workload kind to the path of its PodSpec
+var podSpecPaths = map[string]*field.Path{
+	"Pod":                   field.NewPath("spec"),
+	"PodTemplate":           field.NewPath("template", "spec"),
+	"Deployment":            field.NewPath("spec", "template", "spec"),
+	"ReplicaSet":            field.NewPath("spec", "template", "spec"),
+	"ReplicationController": field.NewPath("spec", "template", "spec"),
+	"StatefulSet":           field.NewPath("spec", "template", "spec"),
+	"DaemonSet":             field.NewPath("spec", "template", "spec"),
+	"Job":                   field.NewPath("spec", "template", "spec"),
+	"CronJob":               field.NewPath("spec", "jobTemplate", "spec", "template", "spec"),
+}
+
+// templatePath is where workload kinds not listed in podSpecPaths, such as
+// custom resources wrapping a pod template, usually keep their PodSpec
+var templatePath = field.NewPath("spec", "template", "spec")
+
+// workload is an object with a PodSpec found in a manifest
+type workload struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Spec is the decoded PodSpec, found at SpecPath in the object
+	Spec     *v1.PodSpec
+	SpecPath *field.Path
+	// Annotations are those of the pod or pod template, found at
+	// AnnotationsPath in the object
+	Annotations     map[string]string
+	AnnotationsPath *field.Path
+	// node is the object's mapping node, used to locate fields
+	node *yaml.Node
+}
+
+// resource names the workload the way findings report it
+func (w *workload) resource() string {
+	if w.Namespace == "" {
+		return w.Kind + " " + w.Name
+	}
+	return w.Kind + " " + w.Namespace + "/" + w.Name
+}
+
+// line returns the line of the field at p, or of its closest parent
+// present in the manifest when the field itself is not set
+func (w *workload) line(p *field.Path) int {
+	node, line := w.node, w.node.Line
+	for _, s := range segments(p) {
+		next := child(node, s)
+		if next == nil {
+			break
+		}
+		// point at the key rather than at a nested value starting on the next line
+		line = next.Line
+		if node.Kind == yaml.MappingNode {
+			line = keyNode(node, s).Line
+		}
+		node = next
+	}
+	return line
+}
+
+// readWorkloads decodes every YAML document in r and returns the workloads
+// found in them, including the items of List objects
+func readWorkloads(name string, r io.Reader) ([]*workload, error) {
+	var workloads []*workload
+	dec := yaml.NewDecoder(r)
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			return workloads, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		found, err := objectWorkloads(doc.Content[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		workloads = append(workloads, found...)
+	}
+}
+
+// objectWorkloads returns the workload in the object at node, or the
+// workloads in its items if the object is a List
+func objectWorkloads(node *yaml.Node) ([]*workload, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	kind := scalar(child(node, "kind"))
+	if strings.HasSuffix(kind, "List") {
+		var workloads []*workload
+		if items := child(node, "items"); items != nil {
+			for _, item := range items.Content {
+				found, err := objectWorkloads(item)
+				if err != nil {
+					return nil, err
+				}
+				workloads = append(workloads, found...)
+			}
+		}
+		return workloads, nil
+	}
+
+	specPath, known := podSpecPaths[kind]
+	if !known {
+		specPath = templatePath
+	}
+	w := &workload{Kind: kind, SpecPath: specPath, node: node}
+	specNode := node
+	for _, s := range segments(specPath) {
+		if specNode = child(specNode, s); specNode == nil {
+			return nil, nil
+		}
+	}
+	// a template of an unknown kind is only taken for a PodSpec if it has containers
+	if !known && child(specNode, "containers") == nil {
+		return nil, nil
+	}
+	if metadata := child(node, "metadata"); metadata != nil {
+		w.Namespace = scalar(child(metadata, "namespace"))
+		w.Name = scalar(child(metadata, "name"))
+	}
+
+	var raw interface{}
+	if err := specNode.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("line %d: %v", specNode.Line, err)
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", specNode.Line, err)
+	}
+	w.Spec = &v1.PodSpec{}
+	if err := json.Unmarshal(data, w.Spec); err != nil {
+		return nil, fmt.Errorf("line %d: invalid PodSpec in %s: %v", specNode.Line, w.resource(), err)
+	}
+
+	// the metadata of the pod template sits next to its spec
+	w.AnnotationsPath = metadataPath(specPath).Child("annotations")
+	annotationsNode := node
+	for _, s := range segments(w.AnnotationsPath) {
+		if annotationsNode = child(annotationsNode, s); annotationsNode == nil {
+			return []*workload{w}, nil
+		}
+	}
+	if err := annotationsNode.Decode(&w.Annotations); err != nil {
+		return nil, fmt.Errorf("line %d: invalid annotations in %s: %v", annotationsNode.Line, w.resource(), err)
+	}
+	return []*workload{w}, nil
+}
+
+// metadataPath returns the path of the metadata of the pod or pod template
+// whose PodSpec is at specPath
+func metadataPath(specPath *field.Path) *field.Path {
+	segs := segments(specPath)
+	if len(segs) == 1 {
+		return field.NewPath("metadata")
+	}
+	return field.NewPath(segs[0], segs[1:len(segs)-1]...).Child("metadata")
+}
+
+// child returns the value of key in a mapping node, or the element at
+// index key in a sequence node. It returns nil if there is no such child.
+func child(node *yaml.Node, key string) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(node.Content) {
+			return node.Content[i]
+		}
+	case yaml.AliasNode:
+		return child(node.Alias, key)
+	}
+	return nil
+}
+
+// keyNode returns the key node of key in a mapping node
+func keyNode(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+// scalar returns the value of a scalar node, or "" for any other node
+func scalar(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// segments splits a field path such as "spec.containers[0].securityContext"
+// into its field names, indices and keys
+func segments(p *field.Path) []string {
+	var segs []string
+	s := p.String()
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return append(segs, s[1:])
+			}
+			segs = append(segs, s[1:end])
+			s = s[end+1:]
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			segs = append(segs, s[:end])
+			s = s[end:]
+		}
+	}
+	return segs
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+// Package scan runs the PodSpec checks against manifests before they reach
+// the cluster, e.g. plain YAML files, `helm template` output or
+// `kustomize build` output.
+package scan
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+)
+
+// manifestExtensions are the file extensions read when scanning a directory
+var manifestExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// Reader scans the manifests read from r. name is used as the file name in
+// the findings' locations.
+func Reader(name string, r io.Reader) ([]report.Finding, error) {
+	workloads, err := readWorkloads(name, r)
+	if err != nil {
+		return nil, err
+	}
+	var findings []report.Finding
+	for _, w := range workloads {
+		violations := checks.EvaluatePodSpec(w.Spec, w.SpecPath)
+		violations = append(violations, checks.EvaluatePodAnnotations(w.Annotations, w.AnnotationsPath)...)
+		for _, v := range violations {
+			findings = append(findings, report.Finding{
+				Check:    v.Check,
+				Resource: w.resource(),
+				Location: fmt.Sprintf("%s:%d", name, w.line(v.Field)),
+				Message:  v.String(),
+			})
+		}
+	}
+	return findings, nil
+}
+
+// Path scans the manifest file at path, or every manifest file found under
+// path if it is a directory
+func Path(path string) ([]report.Finding, error) {
+	var findings []report.Finding
+	err := filepath.Walk(path, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		// files named explicitly are scanned whatever their extension
+		if name != path && !manifestExtensions[strings.ToLower(filepath.Ext(name))] {
+			return nil
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		found, err := Reader(name, f)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, found...)
+		return nil
+	})
+	return findings, err
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package scan
+
+import (
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/report"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("scanning manifests", func() {
+
+	It("should report violations with the file and line of the offending field", func() {
+		findings, err := Path("testdata/workloads.yaml")
+		Ω(err).Should(BeNil())
+
+		Expect(findings).To(ConsistOf(
+			report.Finding{
+				Check:    checks.HostNetwork,
+				Resource: "Deployment web/nginx",
+				Location: "testdata/workloads.yaml:14",
+				Message:  "spec.template.spec.hostNetwork: Host network is not allowed to be used",
+			},
+			report.Finding{
+				Check:    checks.AppArmor,
+				Resource: "Deployment web/nginx",
+				Location: "testdata/workloads.yaml:12",
+				Message: "spec.template.metadata.annotations[container.apparmor.security.beta.kubernetes.io/nginx]: " +
+					"Unconfined AppArmor profile is not allowed",
+			},
+			report.Finding{
+				Check:    checks.Privileged,
+				Resource: "Deployment web/nginx",
+				Location: "testdata/workloads.yaml:22",
+				Message: "spec.template.spec.containers[0].securityContext.privileged: " +
+					"Privileged containers are not allowed",
+			},
+			report.Finding{
+				Check:    checks.Capabilities,
+				Resource: "Deployment web/nginx",
+				Location: "testdata/workloads.yaml:24",
+				Message: "spec.template.spec.containers[0].securityContext.capabilities.add[1]: " +
+					"capability \"SYS_ADMIN\" may not be added",
+			},
+			report.Finding{
+				Check:    checks.HostPID,
+				Resource: "CronJob backup",
+				Location: "testdata/workloads.yaml:40",
+				Message:  "spec.jobTemplate.spec.template.spec.hostPID: Host PID is not allowed to be used",
+			},
+			report.Finding{
+				Check:    checks.Seccomp,
+				Resource: "CronJob backup",
+				Location: "testdata/workloads.yaml:42",
+				Message: "spec.jobTemplate.spec.template.spec.containers[0].securityContext.seccompProfile: " +
+					"seccomp profile must be set to RuntimeDefault or Localhost",
+			},
+			report.Finding{
+				Check:    checks.HostPathVolume,
+				Resource: "CronJob backup",
+				Location: "testdata/workloads.yaml:46",
+				Message: "spec.jobTemplate.spec.template.spec.volumes[0].hostPath: " +
+					"hostPath volumes are not allowed to be used",
+			},
+		))
+	})
+
+	It("should find the PodSpec of unknown kinds wrapping a pod template", func() {
+		findings, err := Reader("rollout.yaml", strings.NewReader(`
+apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: canary
+spec:
+  template:
+    spec:
+      hostIPC: true
+      securityContext:
+        seccompProfile:
+          type: RuntimeDefault
+      containers:
+        - name: app
+          image: app
+`))
+		Ω(err).Should(BeNil())
+		Expect(findings).To(HaveLen(1))
+		Expect(findings[0].Check).To(Equal(checks.HostIPC))
+		Expect(findings[0].Location).To(Equal("rollout.yaml:9"))
+	})
+
+	It("should return an error on malformed manifests", func() {
+		_, err := Reader("bad.yaml", strings.NewReader("kind: Pod\nspec: [\n"))
+		Ω(err).ShouldNot(BeNil())
+		Expect(err.Error()).To(HavePrefix("bad.yaml: "))
+	})
+})
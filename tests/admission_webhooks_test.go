@@ -0,0 +1,72 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"github.com/yahoo/k8s-sec-check/admission"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+//Test cases:
+//  1. Admission webhooks do not fail open
+//  2. ValidatingAdmissionPolicies are bound to deny requests
+
+//	Read every ValidatingWebhookConfiguration and
+//	MutatingWebhookConfiguration, e.g. those of Gatekeeper and Kyverno, and
+//	assert that no webhook taking part in the checks sets failurePolicy
+//	Ignore, excludes namespaces other than KUBE_SYSTEM_NAMESPACES or lets
+//	namespaces or objects opt out through labels, validates pods without
+//	their updates and ephemeral containers or controllers without pods,
+//	times out after more than 10 seconds, or calls a service without a
+//	ready endpoint. Each finding names the checks it could undermine.
+
+//	Read every ValidatingAdmissionPolicy and its bindings, note them, and
+//	assert that every policy matching the requests of the checks is bound
+//	with the Deny action. Policies bound by none, or only to Warn or Audit,
+//	admit the probes they fail.
+
+// https://kubernetes.io/docs/reference/access-authn-authz/extensible-admission-controllers/#failure-policy
+// https://kubernetes.io/docs/reference/access-authn-authz/extensible-admission-controllers/#matching-requests-namespaceselector
+// https://kubernetes.io/docs/reference/access-authn-authz/validating-admission-policy/#validation-actions
+
+//Sample report:
+//	ValidatingWebhookConfiguration gatekeeper-validating-webhook-configuration validation.gatekeeper.sh:
+//	failurePolicy is Ignore, so requests are admitted whenever the webhook fails, undermining the PodSpec checks, ...
+//	ValidatingAdmissionPolicy deny-privileged: is only bound to warn or audit, by deny-privileged-dry-run (Warn, Audit),
+//	so the requests it fails are admitted, undermining the PodSpec checks
+
+var _ = Describe("reading the admission webhooks", func() {
+
+	Context("of policy engines", func() {
+
+		It("should not let requests bypass them [admission-webhooks]", func() {
+			findings, err := admission.Webhooks(util.Context(), client.KubernetesClient, util.SystemNamespaces)
+			Ω(err).Should(BeNil())
+			for _, finding := range findings {
+				report.Record(finding)
+			}
+			Expect(findings).To(BeEmpty())
+		})
+	})
+
+	Context("of ValidatingAdmissionPolicies", func() {
+
+		It("should be bound to deny requests [admission-policy-actions]", func() {
+			policies, err := admission.ValidatingAdmissionPolicies(util.Context(), client.KubernetesClient)
+			Ω(err).Should(BeNil())
+			for _, policy := range policies {
+				report.Note("ValidatingAdmissionPolicy %s", policy)
+			}
+			findings := admission.ValidatingAdmissionPolicyFindings(policies)
+			for _, finding := range findings {
+				report.Record(finding)
+			}
+			Expect(findings).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,95 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"fmt"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+//Test case(s):
+//  Do not admit containers with dangerous capabilities
+//  Containers must drop ALL capabilities
+
+//	Create an unprivileged pod adding one Linux capability at a time, and
+//	record whether admission allowed or denied each, so a single allowed
+//	capability is not hidden behind the privileged flag. Only
+//	NET_BIND_SERVICE may be added under the restricted profile. Also assert
+//	that a container which does not drop ALL capabilities is denied.
+
+// http://man7.org/linux/man-pages/man7/capabilities.7.html
+// https://kubernetes.io/docs/concepts/security/pod-security-standards/#restricted
+
+//Sample report:
+//	AUDIT_CONTROL       denied
+//	...
+//	NET_BIND_SERVICE    allowed
+//	NET_RAW             allowed   (restricted: denied)
+
+// capabilityVerdict names the verdict on adding a capability
+func capabilityVerdict(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
+
+var _ = Describe("creating a pod", func() {
+
+	var podName = "nginx-capability-matrix-pod-test"
+
+	Context("adding a single capability to an unprivileged container", func() {
+
+		It("should only admit the capabilities allowed by the restricted profile [capabilities]", func() {
+			var admitted []v1.Capability
+			for _, capability := range checks.LinuxCapabilities {
+				pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+				pod.Spec.Containers[0].SecurityContext.Capabilities.Add = []v1.Capability{capability}
+
+				_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+				if err != nil && !util.IsAdmissionDenied(err) {
+					Fail(CurrentGinkgoTestDescription().TestText + ": " + err.Error())
+				}
+				allowed := err == nil
+
+				// one row of the allowed/denied table per capability
+				row := fmt.Sprintf("%-20s %s", capability, capabilityVerdict(allowed))
+				if allowed != checks.CapabilityAllowed(capability) {
+					row += "   (restricted: " + capabilityVerdict(checks.CapabilityAllowed(capability)) + ")"
+				}
+				report.Note("%s", row)
+
+				if allowed && !checks.CapabilityAllowed(capability) {
+					admitted = append(admitted, capability)
+					report.Record(report.Finding{
+						Check:    checks.Capabilities,
+						Resource: "Namespace " + util.TargetNamespace,
+						Message:  fmt.Sprintf("capability %q may be added", capability),
+					})
+				}
+			}
+			Expect(admitted).To(BeEmpty())
+		})
+	})
+
+	Context("not dropping ALL capabilities", func() {
+
+		It("should return an error on creating pod [capabilities]", func() {
+			pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+			pod.Spec.Containers[0].SecurityContext.Capabilities.Drop = nil
+			_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+			// assert for an existence of an error
+			Ω(err).ShouldNot(BeNil())
+			// assert if operation is forbidden and do not admit the operation
+			Expect(util.IsAdmissionDenied(err)).To(BeTrue(), err.Error())
+		})
+	})
+})
@@ -0,0 +1,44 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/rbac"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+//Test case:
+//  Ensure that the cluster-admin role is only used where required (CIS 5.1.1)
+
+//	List every ClusterRoleBinding and RoleBinding referencing cluster-admin,
+//	or a role with a rule over all verbs or all resources, and assert that
+//	each subject is listed in KUBE_ALLOWED_ADMIN_SUBJECTS. The default
+//	bindings reconciled by the API server are left out. Each unexpected
+//	subject is reported with the binding and the time it was created.
+
+// https://kubernetes.io/docs/reference/access-authn-authz/rbac/#user-facing-roles
+
+//Sample report:
+//	User alice (owner: ClusterRoleBinding/admins) bound to ClusterRole/cluster-admin since 2019-05-15T00:21:08Z
+
+var _ = Describe("reading the RBAC bindings", func() {
+
+	Context("to cluster-admin and wildcard roles", func() {
+
+		It("should only bind the allowed subjects [cluster-admin-bindings]", func() {
+			snapshot, err := rbac.Load(util.Context(), client.KubernetesClient)
+			Ω(err).Should(BeNil())
+
+			findings := rbac.AdminBindingFindings(snapshot.AdminBindings(), util.AllowedAdminSubjects)
+			for _, finding := range findings {
+				report.Record(finding)
+			}
+			Expect(findings).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,38 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"github.com/yahoo/k8s-sec-check/admission"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/dynamic"
+)
+
+// expectDenied asserts that message, an API error or the failure a
+// controller reported creating its pods, is an admission denial, and notes
+// the policy behind it. Only PodSecurityPolicy words its denials the same
+// way for every cluster, so the messages it gives for each field are only
+// asserted when it made the denial.
+func expectDenied(message string, pspMessages ...string) {
+	denial, ok := admission.ParseDenial(message)
+	Expect(ok).To(BeTrue(), "not an admission denial: "+message)
+
+	dynamicClient, err := dynamic.NewForConfig(client.RestConfig)
+	if err == nil {
+		err = admission.ResolveConstraints(util.Context(), client.KubernetesClient, dynamicClient, &denial)
+	}
+	if err != nil {
+		report.Note("Failed to resolve the gatekeeper constraints: %v", err)
+	}
+	report.Note("denied by %s", denial)
+
+	if denial.Engine == admission.PodSecurityPolicy {
+		for _, m := range pspMessages {
+			Expect(message).To(ContainSubstring(m))
+		}
+	}
+}
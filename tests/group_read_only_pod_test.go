@@ -0,0 +1,277 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+//Test case(s):
+//  Do not admit containers with a writable root filesystem
+//  Do not admit containers running as group 0
+//  Do not admit pods with an fsGroup or supplementalGroups outside of the allowed range
+
+//	Create pods with readOnlyRootFilesystem false or unset, runAsGroup 0 or
+//	unset, and fsGroup or supplementalGroups set to 0, and assert that each
+//	is denied, or mutated into a compliant pod by a policy such as a
+//	MustRunAs rule. Then probe a set of group IDs for runAsGroup, fsGroup and
+//	supplementalGroups, and work out from the admitted dry-run objects which
+//	range the cluster enforced, and what it defaults the fields to. Each
+//	probe changes one field of a pod setting all three to compliant values,
+//	which must be admitted first, so that a policy requiring one of them
+//	is not taken for the enforcement of another. Pods are submitted with
+//	server-side dry-run.
+
+// https://kubernetes.io/docs/tasks/configure-pod-container/security-context/
+// https://kubernetes.io/docs/concepts/policy/pod-security-policy/#users-and-groups
+
+//Sample report:
+//	runAsGroup: admitted 1000 2000 65534 65535, denied 0 1 100 1000000, defaults to 1000
+//	fsGroup: admitted 2000, mutated 0->2000 1->2000, defaults to 2000
+
+// probeGroupIDs are the group IDs submitted to work out the enforced range
+var probeGroupIDs = []int64{0, 1, 100, 1000, 2000, 65534, 65535, 1000000}
+
+// groupField is a group ID field of the pod, which the admitted range is
+// worked out for
+type groupField struct {
+	id   checks.ID
+	name string
+	set  func(pod *v1.Pod, gid int64)
+	// get returns the group IDs the container runs with
+	get func(pod *v1.Pod) []int64
+	// unset leaves the field for admission to default
+	unset func(pod *v1.Pod)
+}
+
+var groupFields = []groupField{
+	{checks.RunAsGroup, "runAsGroup", func(pod *v1.Pod, gid int64) {
+		pod.Spec.SecurityContext.RunAsGroup = &gid
+	}, func(pod *v1.Pod) []int64 {
+		// the container runAsGroup overrides the pod one
+		if sc := pod.Spec.Containers[0].SecurityContext; sc != nil && sc.RunAsGroup != nil {
+			return []int64{*sc.RunAsGroup}
+		}
+		if psc := pod.Spec.SecurityContext; psc != nil && psc.RunAsGroup != nil {
+			return []int64{*psc.RunAsGroup}
+		}
+		return nil
+	}, func(pod *v1.Pod) {
+		pod.Spec.SecurityContext.RunAsGroup = nil
+	}},
+	{checks.FSGroup, "fsGroup", func(pod *v1.Pod, gid int64) {
+		pod.Spec.SecurityContext.FSGroup = &gid
+	}, func(pod *v1.Pod) []int64 {
+		if psc := pod.Spec.SecurityContext; psc != nil && psc.FSGroup != nil {
+			return []int64{*psc.FSGroup}
+		}
+		return nil
+	}, func(pod *v1.Pod) {
+		pod.Spec.SecurityContext.FSGroup = nil
+	}},
+	{checks.FSGroup, "supplementalGroups", func(pod *v1.Pod, gid int64) {
+		pod.Spec.SecurityContext.SupplementalGroups = []int64{gid}
+	}, func(pod *v1.Pod) []int64 {
+		if psc := pod.Spec.SecurityContext; psc != nil {
+			return psc.SupplementalGroups
+		}
+		return nil
+	}, func(pod *v1.Pod) {
+		pod.Spec.SecurityContext.SupplementalGroups = nil
+	}},
+}
+
+// compliantGroupPod returns the restricted nginx pod with a read-only root
+// filesystem, and runAsGroup and fsGroup 1000, for the probes to change
+// one of them at a time
+func compliantGroupPod(podName string) *v1.Pod {
+	readOnlyRootFilesystem := true
+	gid := int64(1000)
+	pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+	pod.Spec.Containers[0].SecurityContext.ReadOnlyRootFilesystem = &readOnlyRootFilesystem
+	pod.Spec.SecurityContext.RunAsGroup = &gid
+	pod.Spec.SecurityContext.FSGroup = &gid
+	return pod
+}
+
+// expectCompliantGroupPodAdmitted asserts that the compliant pod is
+// admitted, or the denials of the probes prove nothing
+func expectCompliantGroupPodAdmitted(podName string) {
+	_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, compliantGroupPod(podName),
+		util.TargetNamespace)
+	Ω(err).Should(BeNil())
+}
+
+// hasReadOnlyRootFilesystem reports whether every container of the pod has
+// a read-only root filesystem
+func hasReadOnlyRootFilesystem(pod *v1.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.SecurityContext == nil || c.SecurityContext.ReadOnlyRootFilesystem == nil ||
+			!*c.SecurityContext.ReadOnlyRootFilesystem {
+			return false
+		}
+	}
+	return true
+}
+
+// groupsNonRoot returns a compliance test that the group IDs of the field
+// are set and none is 0
+func groupsNonRoot(f groupField) func(pod *v1.Pod) bool {
+	return func(pod *v1.Pod) bool {
+		gids := f.get(pod)
+		for _, gid := range gids {
+			if gid == 0 {
+				return false
+			}
+		}
+		return len(gids) > 0
+	}
+}
+
+// formatGroupIDs joins the group IDs with spaces
+func formatGroupIDs(gids []int64) string {
+	s := make([]string, len(gids))
+	for i, gid := range gids {
+		s[i] = fmt.Sprint(gid)
+	}
+	return strings.Join(s, " ")
+}
+
+// enforcedGroupRange probes every group ID for the field, and lists the IDs
+// the cluster admitted, denied and mutated in the dry-run objects
+func enforcedGroupRange(f groupField, podName string) (string, error) {
+	var admitted, denied []int64
+	var mutated []string
+	for _, gid := range probeGroupIDs {
+		pod := compliantGroupPod(podName)
+		f.set(pod, gid)
+		object, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+		if err != nil {
+			if !util.IsAdmissionDenied(err) {
+				return "", err
+			}
+			denied = append(denied, gid)
+			continue
+		}
+		if gids := f.get(object); len(gids) == 1 && gids[0] == gid {
+			admitted = append(admitted, gid)
+		} else {
+			mutated = append(mutated, fmt.Sprintf("%d->%s", gid, formatGroupIDs(gids)))
+		}
+	}
+
+	var summary []string
+	if len(admitted) > 0 {
+		sort.Slice(admitted, func(i, j int) bool { return admitted[i] < admitted[j] })
+		summary = append(summary, "admitted "+formatGroupIDs(admitted))
+	} else {
+		summary = append(summary, "admitted none of the probed IDs")
+	}
+	if len(denied) > 0 {
+		summary = append(summary, "denied "+formatGroupIDs(denied))
+	}
+	if len(mutated) > 0 {
+		summary = append(summary, "mutated "+strings.Join(mutated, " "))
+	}
+
+	// the value admission fills in when the field is left unset
+	pod := compliantGroupPod(podName)
+	f.unset(pod)
+	object, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+	switch {
+	case err != nil && !util.IsAdmissionDenied(err):
+		return "", err
+	case err != nil:
+		summary = append(summary, "denied when unset")
+	case len(f.get(object)) == 0:
+		summary = append(summary, "left unset")
+	default:
+		summary = append(summary, "defaults to "+formatGroupIDs(f.get(object)))
+	}
+	return f.name + ": " + strings.Join(summary, ", "), nil
+}
+
+var _ = Describe("creating a pod", func() {
+
+	var podName = "nginx-group-read-only-pod-test"
+
+	Context("with a writable root filesystem or running as group 0", func() {
+
+		DescribeTable("should return an error on creating pod, or have it mutated into a compliant one",
+			func(id checks.ID, probe string, violate func(pod *v1.Pod), compliant func(pod *v1.Pod) bool) {
+				expectCompliantGroupPodAdmitted(podName)
+				pod := compliantGroupPod(podName)
+				violate(pod)
+
+				outcome, _, err := util.ProbePod(util.Context(), client.KubernetesClient, pod,
+					util.TargetNamespace, compliant)
+				Ω(err).Should(BeNil())
+				report.Note("%s: %s", probe, outcome)
+				if outcome == util.Admitted {
+					report.Record(report.Finding{
+						Check:    id,
+						Resource: "Namespace " + util.TargetNamespace,
+						Message:  probe + " admitted",
+					})
+				}
+				Expect(outcome).NotTo(Equal(util.Admitted))
+			},
+			Entry("with readOnlyRootFilesystem false [read-only-root-filesystem]", checks.ReadOnlyRootFilesystem,
+				"readOnlyRootFilesystem false", func(pod *v1.Pod) {
+					readOnlyRootFilesystem := false
+					pod.Spec.Containers[0].SecurityContext.ReadOnlyRootFilesystem = &readOnlyRootFilesystem
+				}, hasReadOnlyRootFilesystem),
+			Entry("with readOnlyRootFilesystem unset [read-only-root-filesystem]", checks.ReadOnlyRootFilesystem,
+				"readOnlyRootFilesystem unset", func(pod *v1.Pod) {
+					pod.Spec.Containers[0].SecurityContext.ReadOnlyRootFilesystem = nil
+				}, hasReadOnlyRootFilesystem),
+			Entry("with runAsGroup 0 in the pod securityContext [run-as-group]", checks.RunAsGroup,
+				"pod runAsGroup 0", func(pod *v1.Pod) {
+					runAsGroup := int64(0)
+					pod.Spec.SecurityContext.RunAsGroup = &runAsGroup
+				}, groupsNonRoot(groupFields[0])),
+			Entry("with runAsGroup 0 in the container securityContext [run-as-group]", checks.RunAsGroup,
+				"container runAsGroup 0", func(pod *v1.Pod) {
+					runAsGroup := int64(0)
+					pod.Spec.Containers[0].SecurityContext.RunAsGroup = &runAsGroup
+				}, groupsNonRoot(groupFields[0])),
+			Entry("with runAsGroup unset on a root image [run-as-group]", checks.RunAsGroup,
+				"runAsGroup unset", func(pod *v1.Pod) {
+					pod.Spec.SecurityContext.RunAsGroup = nil
+				}, groupsNonRoot(groupFields[0])),
+			Entry("with fsGroup 0 [fs-group]", checks.FSGroup,
+				"fsGroup 0", func(pod *v1.Pod) {
+					fsGroup := int64(0)
+					pod.Spec.SecurityContext.FSGroup = &fsGroup
+				}, groupsNonRoot(groupFields[1])),
+			Entry("with supplementalGroups including 0 [fs-group]", checks.FSGroup,
+				"supplementalGroups 0", func(pod *v1.Pod) {
+					pod.Spec.SecurityContext.SupplementalGroups = []int64{0}
+				}, groupsNonRoot(groupFields[2])),
+		)
+	})
+
+	Context("with group IDs across the range", func() {
+
+		It("should report the group ranges enforced by the cluster [run-as-group] [fs-group]", func() {
+			expectCompliantGroupPodAdmitted(podName)
+			for _, f := range groupFields {
+				summary, err := enforcedGroupRange(f, podName)
+				Ω(err).Should(BeNil())
+				report.Note("%s", summary)
+			}
+		})
+	})
+})
@@ -0,0 +1,116 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"path"
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+)
+
+//Test case(s):
+//  Only admit hostPath volumes under the allowed prefixes, and read-only
+
+//	Clusters may allow hostPath volumes under some prefixes, listed in
+//	KUBE_ALLOWED_HOST_PATHS. For each prefix, create pods mounting it, and a
+//	directory below it, and assert they are only admitted with readOnly set.
+//	Assert that a sibling of the prefix (/var/logs for /var/log) and a path
+//	escaping it (/var/log/../../etc) are denied. Whatever the prefixes, assert
+//	that /, /proc, /var/run/docker.sock and /var/lib/kubelet are denied even
+//	read-only.
+
+// https://kubernetes.io/docs/concepts/storage/volumes/#hostpath
+// https://kubernetes.io/docs/concepts/policy/pod-security-policy/#volumes-and-file-systems
+
+//Sample error output:
+//	Failed to create pod: pods "nginx-host-path-pod-test" is forbidden: unable to validate against any
+//	pod security policy: [spec.containers[0].volumeMounts[0].readOnly: Invalid value: false:
+//	must be read-only]
+
+// sensitiveHostPaths must never be mounted, whatever the allowed prefixes
+var sensitiveHostPaths = []string{"/", "/proc", "/var/run/docker.sock", "/var/lib/kubelet"}
+
+// hostPathPod returns the restricted nginx pod mounting the host path
+func hostPathPod(podName string, hostPath string, readOnly bool) *v1.Pod {
+	pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+	pod.Spec.Volumes = []v1.Volume{
+		{
+			Name:         "hostpath",
+			VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: hostPath}},
+		},
+	}
+	pod.Spec.Containers[0].VolumeMounts = []v1.VolumeMount{
+		{
+			Name:      "hostpath",
+			MountPath: "/datahostpath",
+			ReadOnly:  readOnly,
+		},
+	}
+	return pod
+}
+
+// allowedHostPathEntries returns the admitted and denied probes of every
+// allowed prefix
+func allowedHostPathEntries() (admitted []TableEntry, denied []TableEntry) {
+	for _, prefix := range util.AllowedHostPaths {
+		prefix = path.Clean(prefix)
+		// climb back to the root, whatever the depth of the prefix
+		escaping := prefix + strings.Repeat("/..", strings.Count(prefix, "/")) + "/etc"
+		admitted = append(admitted,
+			Entry(prefix+" read-only [host-path-volume]", prefix, true),
+			Entry(prefix+"/probe read-only [host-path-volume]", prefix+"/probe", true))
+		denied = append(denied,
+			Entry(prefix+" writable [host-path-volume]", prefix, false),
+			Entry(prefix+"/probe writable [host-path-volume]", prefix+"/probe", false),
+			Entry(prefix+"s, a sibling of the prefix [host-path-volume]", prefix+"s", true),
+			Entry(escaping+", escaping the prefix [host-path-volume]", escaping, true))
+	}
+	for _, hostPath := range sensitiveHostPaths {
+		denied = append(denied, Entry(hostPath+" read-only [host-path-volume]", hostPath, true))
+	}
+	return admitted, denied
+}
+
+var _ = Describe("creating a pod", func() {
+
+	var podName = "nginx-host-path-pod-test"
+	admitted, denied := allowedHostPathEntries()
+
+	Context("with a hostPath volume outside of the allowed prefixes or writable", func() {
+
+		DescribeTable("should return an error on creating pod",
+			func(hostPath string, readOnly bool) {
+				pod := hostPathPod(podName, hostPath, readOnly)
+				_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+				// assert for an existence of an error
+				Ω(err).ShouldNot(BeNil())
+				// assert if operation is forbidden and do not admit the operation.
+				// the API server itself may reject a path escaping the prefix as invalid
+				Expect(util.IsAdmissionDenied(err) || kerr.IsInvalid(err)).To(BeTrue(), err.Error())
+			},
+			denied...,
+		)
+	})
+
+	if len(admitted) > 0 {
+		Context("with a read-only hostPath volume under an allowed prefix", func() {
+
+			DescribeTable("should admit the pod",
+				func(hostPath string, readOnly bool) {
+					pod := hostPathPod(podName, hostPath, readOnly)
+					_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+					Ω(err).Should(BeNil())
+				},
+				admitted...,
+			)
+		})
+	}
+})
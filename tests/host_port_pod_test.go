@@ -0,0 +1,110 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"fmt"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+//Test case(s):
+//  Do not admit containers binding host ports outside of the allowed ranges
+
+//	Create pods declaring a hostPort outside of the ranges listed in
+//	KUBE_ALLOWED_HOST_PORTS, with and without hostNetwork, and assert that
+//	each is denied. Every admitted hostPort is reported as its own finding.
+//	If ranges are listed, also assert that a hostPort inside one is admitted
+//	without hostNetwork.
+
+// https://kubernetes.io/docs/concepts/security/pod-security-standards/#baseline
+// https://kubernetes.io/docs/concepts/policy/pod-security-policy/#host-namespaces
+
+//Sample error output:
+//	Failed to create pod: pods "nginx-host-port-pod-test" is forbidden: violates PodSecurity
+//	"baseline:latest": hostPort (container "nginx" uses hostPort 4080)
+
+// probeHostPorts are bound on the host by the checks: the port of the nginx
+// spec, ssh and the kubelet API
+var probeHostPorts = []int32{4080, 22, 10250}
+
+// hostPortPod returns the restricted nginx pod binding the port on the host
+func hostPortPod(podName string, hostPort int32, hostNetwork bool) *v1.Pod {
+	pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+	// with hostNetwork, the container port must be the host port
+	pod.Spec.Containers[0].Ports[0].ContainerPort = hostPort
+	pod.Spec.Containers[0].Ports[0].HostPort = hostPort
+	pod.Spec.HostNetwork = hostNetwork
+	return pod
+}
+
+// inAllowedHostPorts reports whether port is in one of the allowed ranges
+func inAllowedHostPorts(port int32) bool {
+	for _, r := range util.AllowedHostPorts {
+		if r.Contains(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostPortEntries returns a probe of every port outside of the allowed
+// ranges, with and without hostNetwork
+func hostPortEntries() []TableEntry {
+	var entries []TableEntry
+	for _, port := range probeHostPorts {
+		if inAllowedHostPorts(port) {
+			continue
+		}
+		entries = append(entries,
+			Entry(fmt.Sprintf("hostPort %d without hostNetwork [host-ports]", port), port, false),
+			Entry(fmt.Sprintf("hostPort %d with hostNetwork [host-ports] [host-network]", port), port, true))
+	}
+	return entries
+}
+
+var _ = Describe("creating a pod", func() {
+
+	var podName = "nginx-host-port-pod-test"
+
+	Context("binding a host port outside of the allowed ranges", func() {
+
+		DescribeTable("should return an error on creating pod",
+			func(hostPort int32, hostNetwork bool) {
+				pod := hostPortPod(podName, hostPort, hostNetwork)
+				_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+				if err == nil {
+					report.Record(report.Finding{
+						Check:    checks.HostPorts,
+						Resource: "Namespace " + util.TargetNamespace,
+						Message:  fmt.Sprintf("hostPort %d admitted, hostNetwork: %t", hostPort, hostNetwork),
+					})
+				}
+				// assert for an existence of an error
+				Ω(err).ShouldNot(BeNil())
+				// assert if operation is forbidden and do not admit the operation
+				Expect(util.IsAdmissionDenied(err)).To(BeTrue(), err.Error())
+			},
+			hostPortEntries()...,
+		)
+	})
+
+	if len(util.AllowedHostPorts) > 0 {
+		Context("binding a host port inside an allowed range", func() {
+
+			It("should admit the pod without hostNetwork [host-ports]", func() {
+				pod := hostPortPod(podName, util.AllowedHostPorts[0].Min, false)
+				_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+				Ω(err).Should(BeNil())
+			})
+		})
+	}
+})
@@ -3,13 +3,20 @@
 package tests
 
 import (
+	"fmt"
 	"log"
+	"strings"
 
+	"github.com/yahoo/k8s-sec-check/checks"
 	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/rbac"
+	"github.com/yahoo/k8s-sec-check/report"
 	"github.com/yahoo/k8s-sec-check/util"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -17,70 +24,188 @@ import (
 //Test case:
 //  User impersonation
 
-//	Create a deployment with impersonated user and it should return an error and failed
-//  to create the deployment. More information on impersonation on kubernetes is available
-//  on the following page.
+//	Impersonate a user, a group alone, a user with the system:masters group,
+//	service accounts (system:serviceaccount:ns:name), a uid and a user
+//	extra, and with each identity list the namespaces at the cluster scope
+//	and create a privileged deployment in the target namespace. Each
+//	request should fail on the impersonation itself. Every permitted
+//	impersonation path is reported with the RBAC bindings granting it. More
+//	information on impersonation on kubernetes is available on the following
+//	page.
 
 // https://kubernetes.io/docs/reference/access-authn-authz/authentication/#user-impersonation
 
-// Following is a table test where different users can be used to run the same test case.
-// Test runs as a cd user and a random user and checks if impersonation operation can be
+// Following is a table test where different identities can be impersonated to run the
+// same test cases. Test runs as a cd user and checks if impersonation operation can be
 // successful or not.
 
-var _ = Describe("creating a deployment", func() {
+//Sample error output:
+//	Failed to create Deployment: users "wronguser" is forbidden: User "cd-user" cannot
+//	impersonate resource "users" in API group "" at the cluster scope
+
+// impersonation is an identity to impersonate
+type impersonation struct {
+	name   string
+	config rest.ImpersonationConfig
+}
+
+// impersonations are impersonated against each impersonationTarget
+var impersonations = []impersonation{
+	{"user wronguser", rest.ImpersonationConfig{UserName: "wronguser"}},
+	{"user wronguser in group system:masters", rest.ImpersonationConfig{
+		UserName: "wronguser",
+		Groups:   []string{"system:masters"},
+	}},
+	// the API server refuses groups without a user, whatever the permissions
+	{"group system:masters alone", rest.ImpersonationConfig{Groups: []string{"system:masters"}}},
+	{"service account kube-system/clusterrole-aggregation-controller", rest.ImpersonationConfig{
+		UserName: "system:serviceaccount:kube-system:clusterrole-aggregation-controller",
+	}},
+	{"service account " + util.TargetNamespace + "/default", rest.ImpersonationConfig{
+		UserName: "system:serviceaccount:" + util.TargetNamespace + ":default",
+	}},
+	{"user wronguser with uid 0", rest.ImpersonationConfig{UserName: "wronguser", UID: "0"}},
+	{"user wronguser with the extra scopes=cluster-admin", rest.ImpersonationConfig{
+		UserName: "wronguser",
+		// Extra is a free-form field which can be used to link some authentication information
+		// to authorization information.  This field allows you to impersonate it.
+		Extra: map[string][]string{"scopes": {"cluster-admin"}},
+	}},
+}
+
+// impersonationTarget is a request sent as the impersonated identity
+type impersonationTarget struct {
+	name    string
+	cluster bool
+	send    func(kc kubernetes.Interface, deploymentName string) error
+}
+
+var impersonationTargets = []impersonationTarget{
+	{"list namespaces", true, func(kc kubernetes.Interface, deploymentName string) error {
+		_, err := kc.CoreV1().Namespaces().List(util.Context(), metav1.ListOptions{})
+		return err
+	}},
+	{"create a privileged deployment", false, func(kc kubernetes.Interface, deploymentName string) error {
+		// create deployment with privilege true and replicacount set to 1
+		deployment := GetNginxDeploymentSpec(util.TargetNamespace, deploymentName, 1, true)
+		deployment.Spec.Template.Spec.HostNetwork = true
+		deployment.Spec.Template.Spec.HostPID = true
+		deployment.Spec.Template.Spec.HostIPC = true
+
+		// create deployment with whitelisted service account name matching with the namespace
+		deployment.Spec.Template.Spec.ServiceAccountName = util.TargetServiceAccount
+		return util.CreateController(util.Context(), kc, deployment, util.TargetNamespace)
+	}},
+}
+
+// impersonationEntries returns an entry per identity and target
+func impersonationEntries() []TableEntry {
+	var entries []TableEntry
+	for _, i := range impersonations {
+		for _, t := range impersonationTargets {
+			entries = append(entries, Entry(fmt.Sprintf("Impersonate as %s to %s", i.name, t.name), i, t))
+		}
+	}
+	return entries
+}
+
+// impersonationDenied reports whether the request failed on the
+// impersonation itself, rather than being handled as the impersonated
+// identity. It fails the spec on errors which show neither, e.g. a timeout
+// or a server error.
+func impersonationDenied(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case kerr.IsForbidden(err):
+		// any other refusal, e.g. by RBAC or admission, is made to the
+		// impersonated identity
+		return strings.Contains(err.Error(), "cannot impersonate")
+	case kerr.IsBadRequest(err):
+		if strings.Contains(err.Error(), "without impersonating a user") {
+			return true
+		}
+	case kerr.IsAlreadyExists(err), kerr.IsInvalid(err):
+		// the request was authorized
+		return false
+	}
+	Fail(CurrentGinkgoTestDescription().TestText + ": " + err.Error())
+	return false
+}
+
+// impersonationGrants names the RBAC bindings granting the caller each
+// impersonate request of the impersonation
+func impersonationGrants(impersonate rest.ImpersonationConfig) string {
+	self, err := rbac.Self(util.Context(), client.KubernetesClient)
+	if err != nil {
+		return "unknown, " + err.Error()
+	}
+	snapshot, err := rbac.Load(util.Context(), client.KubernetesClient)
+	if err != nil {
+		return "unknown, " + err.Error()
+	}
+	var grants []string
+	for _, attrs := range rbac.ImpersonationAttributes(impersonate) {
+		var bindings []string
+		for _, g := range snapshot.Grants(self, attrs) {
+			bindings = append(bindings, g.String())
+		}
+		if len(bindings) == 0 {
+			// e.g. system:masters, or an authorizer other than RBAC
+			bindings = append(bindings, "no RBAC binding")
+		}
+		grants = append(grants, attrs.String()+" by "+strings.Join(bindings, ", "))
+	}
+	return self.Name + ": " + strings.Join(grants, "; ")
+}
+
+var _ = Describe("impersonating a user, group or service account", func() {
 
 	var deploymentName = "nginx-priv-impersonation"
 
-	Context("as Impersonated user or group with Privileged container enabled", func() {
-
-		DescribeTable("impersonate as user to create prvileged deployment",
-			func(ImpersonationUser string) {
-				client.RestConfig.Impersonate = rest.ImpersonationConfig{
-					// UserName is the username to impersonate on each request.
-					UserName: ImpersonationUser,
-					// Groups are the groups to impersonate on each request.
-					Groups: []string{"system:masters"},
-					// Extra is a free-form field which can be used to link some authentication information
-					// to authorization information.  This field allows you to impersonate it.
-					//Extra: ImpersonateUserExtra,
-				}
+	Context("to send requests at the cluster and namespace scope", func() {
+
+		DescribeTable("should fail on the impersonation [impersonation]",
+			func(i impersonation, t impersonationTarget) {
+				config := rest.CopyConfig(client.RestConfig)
+				config.Impersonate = i.config
 				log.Println(CurrentGinkgoTestDescription().FullTestText +
-					": Creating deployment as user: " +
-					client.RestConfig.Impersonate.UserName)
+					": Sending request as " + i.name)
 
-				kc, err := kubernetes.NewForConfig(client.RestConfig)
+				kc, err := kubernetes.NewForConfig(config)
 				if err != nil {
 					Fail(CurrentGinkgoTestDescription().FullTestText +
 						": Failed to get kubernetes client set" + err.Error())
 				}
 
-				// create deployment with privilege true and replicacount set to 1
-				deployment := GetNginxDeploymentSpec(util.TargetNamespace, deploymentName, 1, true)
-				deployment.Spec.Template.Spec.HostNetwork = true
-				deployment.Spec.Template.Spec.HostPID = true
-				deployment.Spec.Template.Spec.HostIPC = true
-
-				// create deployment with whitelisted service account name matching with the namespace
-				deployment.Spec.Template.Spec.ServiceAccountName = util.TargetServiceAccount
-
-				err = util.CreateDeployment(kc, deployment, util.TargetNamespace)
+				err = t.send(kc, deploymentName)
+				if !impersonationDenied(err) {
+					resource := "Namespace " + util.TargetNamespace
+					if t.cluster {
+						resource = "Cluster"
+					}
+					report.Record(report.Finding{
+						Check:    checks.Impersonation,
+						Resource: resource,
+						Message: fmt.Sprintf("may impersonate %s to %s, granted to %s",
+							i.name, t.name, impersonationGrants(i.config)),
+					})
+				}
 
 				// it should return an error as operation is forbidden.
 				// NOTE: if you're a cluster admin and running this test, if will fail
 				// as cluster admin user as a permission to impersonate.
 				Ω(err).ShouldNot(BeNil())
-				// if error happens, make sure it matches with forbidden message
-				Expect(err.Error()).To(MatchRegexp("Failed to create deployment: users \".*\" is forbidden: " +
-					"User \".*\" cannot impersonate resource \"users\" in API group \"\" at the cluster scope"))
+				Expect(impersonationDenied(err)).To(BeTrue(), err.Error())
 			},
-			// as an random non-existent user
-			Entry("Impersonate as wronguser", "wronguser"),
+			impersonationEntries()...,
 		)
 	})
 
 	AfterEach(func() {
 		// delete the deployment once the test is complete
-		err := util.DeleteDeployment(client.KubernetesClient, deploymentName, util.TargetNamespace)
+		err := util.DeleteController(util.Context(), client.KubernetesClient,
+			util.DeploymentKind, deploymentName, util.TargetNamespace)
 		if err != nil {
 			GinkgoT().Logf("%s Failed in teardown. %v:%d err: %v %s",
 				redColor,
@@ -91,4 +216,4 @@ var _ = Describe("creating a deployment", func() {
 			)
 		}
 	})
-})
\ No newline at end of file
+})
@@ -5,10 +5,13 @@ package tests
 
 import (
 	"log"
+	"os"
 	"testing"
 
+	"github.com/yahoo/k8s-sec-check/admission"
 	"github.com/yahoo/k8s-sec-check/client"
 	"github.com/yahoo/k8s-sec-check/util"
+	"github.com/yahoo/k8s-sec-check/report"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -23,6 +26,9 @@ var err error
 
 var _ = BeforeSuite(func() {
 
+	// cancel in-flight checks on SIGINT/SIGTERM, ginkgo then runs AfterSuite
+	util.SetupSignalHandler()
+
 	client.KubernetesClient, client.RestConfig, err = client.GetClients()
 	if err != nil {
 		GinkgoT().
@@ -30,13 +36,30 @@ var _ = BeforeSuite(func() {
 	}
 	log.Println("Target Namespace: " + util.TargetNamespace)
 	log.Println("Target Service Account: " + util.TargetServiceAccount)
+
+	// the checks expect denials of whichever policy engines enforce admission
+	if engines, err := admission.Engines(util.Context(), client.KubernetesClient); err != nil {
+		GinkgoT().Logf("%s Failed to discover policy engines. err: %v %s", redColor, err.Error(), defaultStyle)
+	} else if len(engines) != 0 {
+		log.Printf("Policy engines: %v", engines)
+	}
 })
 
 var _ = AfterSuite(func() {
+	// remove anything the checks left behind, e.g. when a spec was interrupted
+	// before its AfterEach could run
+	if err := util.RunCleanups(util.CleanupGracePeriod); err != nil {
+		GinkgoT().Logf("%s Failed in cleanup. err: %v %s", redColor, err.Error(), defaultStyle)
+	}
 	log.Println("Done running K8s Cluster Check Tests")
 })
 
 func TestK8sCISCheckTests(t *testing.T) {
+	format, err := report.ParseFormat(util.ReportFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
 	RegisterFailHandler(Fail)
-	RunSpecs(t, "K8s Cluster CIS Check")
+	RunSpecsWithDefaultAndCustomReporters(t, "K8s Cluster CIS Check",
+		[]Reporter{report.NewSpecReporter(os.Stdout, format, util.Interrupted)})
 }
@@ -0,0 +1,51 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"github.com/yahoo/k8s-sec-check/audit"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+//Test case:
+//  Every namespace enforces the baseline or restricted Pod Security level
+
+//	Read the pod-security.kubernetes.io/enforce, audit and warn labels, and
+//	their versions, of every namespace and note them in the report. Assert
+//	that every namespace outside of KUBE_PSS_EXEMPT_NAMESPACES labels the
+//	baseline or restricted level to enforce, and that every exempt namespace
+//	exists and still needs its exemption. A default level set in the
+//	AdmissionConfiguration of the API server cannot be read, so namespaces
+//	relying on it are reported too.
+
+// https://kubernetes.io/docs/concepts/security/pod-security-admission/#pod-security-admission-labels-for-namespaces
+
+//Sample report:
+//	kube-system: enforce=privileged@latest audit=unset warn=unset
+//	Namespace api sets no pod-security.kubernetes.io/enforce label, so only the cluster default level
+//	applies (enforce=unset audit=restricted@latest warn=unset)
+
+var _ = Describe("reading the Pod Security labels", func() {
+
+	Context("of every namespace", func() {
+
+		It("should enforce baseline or restricted outside of the exempt namespaces [namespace-pod-security]", func() {
+			namespaces, err := audit.PodSecurity(util.Context(), client.KubernetesClient, "")
+			Ω(err).Should(BeNil())
+			for _, n := range namespaces {
+				report.Note("%s: %s", n.Namespace, n)
+			}
+
+			findings := audit.PodSecurityFindings(namespaces, util.PodSecurityExemptNamespaces)
+			for _, finding := range findings {
+				report.Record(finding)
+			}
+			Expect(findings).To(BeEmpty())
+		})
+	})
+})
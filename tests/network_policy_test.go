@@ -0,0 +1,204 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/audit"
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//Test case(s):
+//  Ensure that all Namespaces have Network Policies defined (CIS 5.3.2)
+//  Connections between namespaces are blocked
+
+//	Audit the NetworkPolicies of every namespace but KUBE_SYSTEM_NAMESPACES,
+//	and assert that each namespace has a policy denying all ingress and a
+//	policy denying all egress traffic by default, and that every pod not
+//	using the host network is selected by a policy.
+//	If KUBE_NETWORK_PROBE_NAMESPACE is set, deploy nginx in one of the
+//	target and probe namespaces, run a pod in the other which connects to
+//	it, and assert that the connection is blocked, in both directions.
+//	A pod in the namespace of nginx must connect to it first, through a
+//	NetworkPolicy allowing the probe pods of the namespace to reach one
+//	another, or the blocked connection proves nothing. Only a timeout or a
+//	refused connection counts as blocked. The probe pods comply with the
+//	restricted profile, so they run in namespaces enforcing it.
+
+// https://kubernetes.io/docs/concepts/services-networking/network-policies/#default-policies
+
+//Sample report:
+//	Namespace web no NetworkPolicy denies all Egress traffic by default
+//	Pod web/nginx-7d9c6b5f4-x2x9z (owner: Deployment web/nginx) no NetworkPolicy selects the pod
+//	Namespace k8s-sec-check connected from namespace k8s-sec-check-probe to nginx-network-probe
+
+// probeConnectCommand prints whether the client reached the server, whose
+// IP is passed as $0, within 5 seconds. curl exits with 7 when the
+// connection is refused and 28 when it times out; any other failure, e.g.
+// a missing binary, is printed as is.
+var probeConnectCommand = []string{"sh", "-c", `curl -s -m 5 -o /dev/null "http://$0:8080/"; code=$?; ` +
+	`case $code in 0) echo connected ;; 7|28) echo blocked ;; *) echo "curl exited with $code" ;; esac`}
+
+// networkProbeLabel labels the probe pods, which probeAllowPolicy lets
+// reach one another
+const networkProbeLabel = "k8s-sec-check/network-probe"
+
+// probeAllowPolicy allows the probe pods of the namespace to connect to one
+// another despite a default-deny policy, leaving the traffic from other
+// namespaces to the policies under test
+func probeAllowPolicy(namespace string) *networkingv1.NetworkPolicy {
+	probes := metav1.LabelSelector{MatchLabels: map[string]string{networkProbeLabel: "true"}}
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8s-sec-check-network-probe", Namespace: namespace},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: probes,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{{PodSelector: &probes}},
+			}},
+			Egress: []networkingv1.NetworkPolicyEgressRule{{
+				To: []networkingv1.NetworkPolicyPeer{{PodSelector: &probes}},
+			}},
+		},
+	}
+}
+
+// probeConnect runs a client pod in the namespace which connects to the
+// server IP, and returns whether it "connected" or was "blocked". It fails
+// the spec on any other outcome.
+func probeConnect(namespace string, podName string, ip string) string {
+	pod := GetRestrictedNginxPodSpec(namespace, podName)
+	pod.Labels = map[string]string{networkProbeLabel: "true"}
+	pod.Spec.RestartPolicy = v1.RestartPolicyNever
+	pod.Spec.Containers[0].Command = append(append([]string{}, probeConnectCommand...), ip)
+	err := util.CreatePod(util.Context(), client.KubernetesClient, pod, namespace)
+	Ω(err).Should(BeNil())
+
+	// wait up to 2 minutes for the client to print the outcome
+	logs, err := util.PodLogs(util.Context(), client.KubernetesClient, podName, namespace, 12)
+	if err != nil {
+		Fail(CurrentGinkgoTestDescription().TestText + ":" + err.Error())
+	}
+	outcome := strings.TrimSpace(logs)
+	if outcome != "connected" && outcome != "blocked" {
+		Fail(CurrentGinkgoTestDescription().TestText + ": client in " + namespace + ": " + outcome)
+	}
+	return outcome
+}
+
+var _ = Describe("auditing the network policies", func() {
+
+	Context("of every namespace", func() {
+
+		DescribeTable("should find nothing",
+			func(id checks.ID) {
+				findings, err := audit.NetworkPolicies(util.Context(), client.KubernetesClient, "",
+					util.SystemNamespaces)
+				Ω(err).Should(BeNil())
+
+				var found []report.Finding
+				for _, finding := range findings {
+					if finding.Check == id {
+						report.Record(finding)
+						found = append(found, finding)
+					}
+				}
+				Expect(found).To(BeEmpty())
+			},
+			Entry("missing a default-deny ingress or egress policy [default-deny-network-policy]",
+				checks.DefaultDenyNetworkPolicy),
+			Entry("leaving pods unselected [network-policy-coverage]", checks.NetworkPolicyCoverage),
+		)
+	})
+})
+
+var _ = Describe("connecting between namespaces", func() {
+
+	var deploymentName = "nginx-network-probe"
+	var podName = "nginx-network-probe-client"
+	var controlPodName = "nginx-network-probe-control"
+	var serverNamespace, clientNamespace string
+
+	Context("with probe pods", func() {
+
+		DescribeTable("should be blocked [namespace-isolation]",
+			func(intoTarget bool) {
+				if util.NetworkProbeNamespace == "" {
+					Skip("KUBE_NETWORK_PROBE_NAMESPACE is not set")
+				}
+				serverNamespace, clientNamespace = util.NetworkProbeNamespace, util.TargetNamespace
+				if intoTarget {
+					serverNamespace, clientNamespace = clientNamespace, serverNamespace
+				}
+
+				err := util.CreateNetworkPolicy(util.Context(), client.KubernetesClient,
+					probeAllowPolicy(serverNamespace), serverNamespace)
+				Ω(err).Should(BeNil())
+				deployment := GetRestrictedNginxDeploymentSpec(serverNamespace, deploymentName)
+				deployment.Spec.Template.Labels[networkProbeLabel] = "true"
+				err = util.CreateDeployment(util.Context(), client.KubernetesClient, deployment, serverNamespace)
+				Ω(err).Should(BeNil())
+				err = util.CheckReadyReplicas(util.Context(), client.KubernetesClient, deploymentName,
+					serverNamespace, 10)
+				Ω(err).Should(BeNil())
+				ip, err := util.PodIP(util.Context(), client.KubernetesClient, deploymentName, serverNamespace)
+				Ω(err).Should(BeNil())
+
+				// the server must be reachable from its own namespace, or a
+				// blocked connection proves nothing
+				control := probeConnect(serverNamespace, controlPodName, ip)
+				Expect(control).To(Equal("connected"), "from the namespace of the server, "+serverNamespace)
+
+				outcome := probeConnect(clientNamespace, podName, ip)
+				report.Note("%s from %s to %s", outcome, clientNamespace, serverNamespace)
+				if outcome == "connected" {
+					report.Record(report.Finding{
+						Check:    checks.NamespaceIsolation,
+						Resource: "Namespace " + serverNamespace,
+						Message:  "connected from namespace " + clientNamespace + " to " + deploymentName,
+					})
+				}
+				Expect(outcome).To(Equal("blocked"))
+			},
+			Entry("into the target namespace", true),
+			Entry("out of the target namespace", false),
+		)
+
+		AfterEach(func() {
+			if util.NetworkProbeNamespace == "" {
+				return
+			}
+			// delete the probe pods once the test is complete
+			errs := []error{
+				util.DeleteDeployment(util.Context(), client.KubernetesClient, deploymentName, serverNamespace),
+				util.DeletePod(util.Context(), client.KubernetesClient, controlPodName, serverNamespace),
+				util.DeletePod(util.Context(), client.KubernetesClient, podName, clientNamespace),
+				util.DeleteNetworkPolicy(util.Context(), client.KubernetesClient,
+					probeAllowPolicy(serverNamespace).Name, serverNamespace),
+			}
+			for _, err := range errs {
+				if err == nil {
+					continue
+				}
+				GinkgoT().Logf("%s Failed in teardown. %v:%d err: %v %s",
+					redColor,
+					CurrentGinkgoTestDescription().FileName,
+					CurrentGinkgoTestDescription().LineNumber,
+					err.Error(),
+					defaultStyle,
+				)
+			}
+		})
+	})
+})
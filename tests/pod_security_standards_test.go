@@ -0,0 +1,202 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"fmt"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+//Test case(s):
+//  Enforce the Pod Security Standards baseline and restricted profiles
+
+//	For every control of the baseline and restricted profiles, submit a pod
+//	complying with the restricted profile and the same pod violating the
+//	control, and assert that only the compliant pod is admitted. Pods are
+//	submitted with server-side dry-run, so nothing is created in the cluster.
+//	A last check probes every control and reports the level actually enforced.
+//	HostProcess probes must also use the host network, so their denial only
+//	counts if it names hostProcess.
+
+// https://kubernetes.io/docs/concepts/security/pod-security-standards/
+// https://kubernetes.io/docs/concepts/security/pod-security-admission/
+
+const (
+	baselineLevel   = "baseline"
+	restrictedLevel = "restricted"
+	privilegedLevel = "privileged"
+)
+
+// podSecurityControl is a control of the Pod Security Standards. violate
+// turns a pod complying with the restricted profile into one breaching the
+// control.
+type podSecurityControl struct {
+	id      checks.ID
+	level   string
+	name    string
+	violate func(pod *v1.Pod)
+}
+
+var podSecurityControls = []podSecurityControl{
+	{checks.HostProcess, baselineLevel, "HostProcess", func(pod *v1.Pod) {
+		// HostProcess pods must use the host network to pass validation
+		hostProcess := true
+		pod.Spec.HostNetwork = true
+		pod.Spec.SecurityContext.WindowsOptions = &v1.WindowsSecurityContextOptions{HostProcess: &hostProcess}
+	}},
+	{checks.HostNetwork, baselineLevel, "Host Namespaces: hostNetwork", func(pod *v1.Pod) {
+		pod.Spec.HostNetwork = true
+	}},
+	{checks.HostPID, baselineLevel, "Host Namespaces: hostPID", func(pod *v1.Pod) {
+		pod.Spec.HostPID = true
+	}},
+	{checks.HostIPC, baselineLevel, "Host Namespaces: hostIPC", func(pod *v1.Pod) {
+		pod.Spec.HostIPC = true
+	}},
+	{checks.Privileged, baselineLevel, "Privileged Containers", func(pod *v1.Pod) {
+		// privileged containers may not disallow privilege escalation
+		privileged := true
+		pod.Spec.Containers[0].SecurityContext.Privileged = &privileged
+		pod.Spec.Containers[0].SecurityContext.AllowPrivilegeEscalation = nil
+	}},
+	{checks.Capabilities, baselineLevel, "Capabilities", func(pod *v1.Pod) {
+		pod.Spec.Containers[0].SecurityContext.Capabilities.Add = []v1.Capability{"SYS_ADMIN"}
+	}},
+	{checks.HostPathVolume, baselineLevel, "HostPath Volumes", func(pod *v1.Pod) {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+			Name:         "hostpath",
+			VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/etc"}},
+		})
+	}},
+	{checks.HostPorts, baselineLevel, "Host Ports", func(pod *v1.Pod) {
+		pod.Spec.Containers[0].Ports[0].HostPort = 4080
+	}},
+	{checks.AppArmor, baselineLevel, "AppArmor", func(pod *v1.Pod) {
+		pod.Spec.Containers[0].SecurityContext.AppArmorProfile = &v1.AppArmorProfile{
+			Type: v1.AppArmorProfileTypeUnconfined,
+		}
+	}},
+	{checks.SELinux, baselineLevel, "SELinux", func(pod *v1.Pod) {
+		pod.Spec.Containers[0].SecurityContext.SELinuxOptions = &v1.SELinuxOptions{Type: "spc_t"}
+	}},
+	{checks.ProcMount, baselineLevel, "/proc Mount Type", func(pod *v1.Pod) {
+		// an unmasked /proc is only valid in a user namespace
+		hostUsers := false
+		procMount := v1.UnmaskedProcMount
+		pod.Spec.HostUsers = &hostUsers
+		pod.Spec.Containers[0].SecurityContext.ProcMount = &procMount
+	}},
+	{checks.Seccomp, baselineLevel, "Seccomp: Unconfined", func(pod *v1.Pod) {
+		pod.Spec.SecurityContext.SeccompProfile = &v1.SeccompProfile{Type: v1.SeccompProfileTypeUnconfined}
+	}},
+	{checks.Sysctls, baselineLevel, "Sysctls", func(pod *v1.Pod) {
+		pod.Spec.SecurityContext.Sysctls = []v1.Sysctl{{Name: "kernel.msgmax", Value: "65536"}}
+	}},
+	{checks.VolumeTypes, restrictedLevel, "Volume Types", func(pod *v1.Pod) {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+			Name:         "nfs",
+			VolumeSource: v1.VolumeSource{NFS: &v1.NFSVolumeSource{Server: "nfs.example.com", Path: "/"}},
+		})
+	}},
+	{checks.PrivilegeEscalation, restrictedLevel, "Privilege Escalation", func(pod *v1.Pod) {
+		allowPrivilegeEscalation := true
+		pod.Spec.Containers[0].SecurityContext.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+	}},
+	{checks.RunAsNonRoot, restrictedLevel, "Running as Non-root", func(pod *v1.Pod) {
+		runAsNonRoot := false
+		pod.Spec.SecurityContext.RunAsNonRoot = &runAsNonRoot
+	}},
+	{checks.RunAsUser, restrictedLevel, "Running as Non-root user", func(pod *v1.Pod) {
+		runAsUser := int64(0)
+		pod.Spec.SecurityContext.RunAsUser = &runAsUser
+	}},
+	{checks.Seccomp, restrictedLevel, "Seccomp: unset", func(pod *v1.Pod) {
+		pod.Spec.SecurityContext.SeccompProfile = nil
+	}},
+	{checks.Capabilities, restrictedLevel, "Capabilities: ALL not dropped", func(pod *v1.Pod) {
+		pod.Spec.Containers[0].SecurityContext.Capabilities.Drop = nil
+	}},
+}
+
+// violatingPod returns the restricted nginx pod breaching control i
+func violatingPod(i int) *v1.Pod {
+	pod := GetRestrictedNginxPodSpec(util.TargetNamespace, fmt.Sprintf("pod-security-probe-%d", i))
+	podSecurityControls[i].violate(pod)
+	return pod
+}
+
+// podSecurityEntries returns a table entry per control
+func podSecurityEntries() []TableEntry {
+	entries := make([]TableEntry, len(podSecurityControls))
+	for i, c := range podSecurityControls {
+		entries[i] = Entry(fmt.Sprintf("%s %s [%s]", c.level, c.name, c.id), i)
+	}
+	return entries
+}
+
+var _ = Describe("submitting a pod", func() {
+
+	Context("violating a single Pod Security Standards control", func() {
+
+		DescribeTable("should be denied while the compliant pod is admitted",
+			func(i int) {
+				// the compliant pod must be admitted, or the denial below proves nothing
+				compliant := GetRestrictedNginxPodSpec(util.TargetNamespace, "pod-security-compliant")
+				_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, compliant, util.TargetNamespace)
+				Ω(err).Should(BeNil())
+
+				_, err = util.DryRunCreatePod(util.Context(), client.KubernetesClient, violatingPod(i), util.TargetNamespace)
+				Ω(err).ShouldNot(BeNil())
+				Expect(util.IsAdmissionDenied(err)).To(BeTrue(), err.Error())
+				if podSecurityControls[i].id == checks.HostProcess && !namesHostProcess(err.Error()) {
+					report.Note("%s: denied, cause indistinguishable from hostNetwork", podSecurityControls[i].name)
+					Skip(podSecurityControls[i].name + " denied, cause indistinguishable from hostNetwork")
+				}
+			},
+			podSecurityEntries()...,
+		)
+	})
+
+	Context("violating each Pod Security Standards control in turn", func() {
+
+		It("should show the restricted level is enforced [pod-security-level]", func() {
+			admitted := map[string]int{}
+			for i, c := range podSecurityControls {
+				_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, violatingPod(i), util.TargetNamespace)
+				if err != nil && !util.IsAdmissionDenied(err) {
+					Fail(CurrentGinkgoTestDescription().TestText + ": " + err.Error())
+				}
+				if err != nil && c.id == checks.HostProcess && !namesHostProcess(err.Error()) {
+					report.Note("%s %s: denied, cause indistinguishable from hostNetwork", c.level, c.name)
+				}
+				if err == nil {
+					admitted[c.level]++
+					report.Record(report.Finding{
+						Check:    c.id,
+						Resource: "Namespace " + util.TargetNamespace,
+						Message:  "admitted a pod violating the " + c.level + " control " + c.name,
+					})
+				}
+			}
+
+			// restricted includes every baseline control
+			level := restrictedLevel
+			if admitted[baselineLevel] > 0 {
+				level = privilegedLevel
+			} else if admitted[restrictedLevel] > 0 {
+				level = baselineLevel
+			}
+			report.Note("enforced level: %s", level)
+			Expect(level).To(Equal(restrictedLevel))
+		})
+	})
+})
@@ -0,0 +1,109 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+//Test case(s):
+//  Minimize the admission of containers with allowPrivilegeEscalation
+
+//	Create pods with allowPrivilegeEscalation set to true, and left unset, and
+//	assert that the policy denies them or rewrites the field to false. A
+//	rewrite by a mutating policy is reported as its own outcome.
+//	allowPrivilegeEscalation false sets no_new_privs on the container process,
+//	which stops setuid binaries from gaining privileges. A compliant pod is
+//	run to assert that the runtime actually sets it.
+
+// https://kubernetes.io/docs/concepts/security/pod-security-standards/#restricted
+// https://www.kernel.org/doc/Documentation/prctl/no_new_privs.txt
+
+//Sample error output:
+//	Failed to create pod: pods "nginx-privilege-escalation-pod-test" is forbidden: violates PodSecurity
+//	"restricted:latest": allowPrivilegeEscalation != false (container "nginx" must set
+//	securityContext.allowPrivilegeEscalation=false)
+
+// disallowsPrivilegeEscalation reports whether every container of the pod
+// sets allowPrivilegeEscalation to false
+func disallowsPrivilegeEscalation(pod *v1.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.SecurityContext == nil || c.SecurityContext.AllowPrivilegeEscalation == nil ||
+			*c.SecurityContext.AllowPrivilegeEscalation {
+			return false
+		}
+	}
+	return true
+}
+
+var _ = Describe("creating a pod", func() {
+
+	Context("allowing privilege escalation", func() {
+
+		var podName = "nginx-privilege-escalation-pod-test"
+
+		DescribeTable("should return an error on creating pod, or have it rewritten to false",
+			func(allowPrivilegeEscalation *bool) {
+				pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+				pod.Spec.Containers[0].SecurityContext.AllowPrivilegeEscalation = allowPrivilegeEscalation
+
+				outcome, _, err := util.ProbePod(util.Context(), client.KubernetesClient, pod,
+					util.TargetNamespace, disallowsPrivilegeEscalation)
+				Ω(err).Should(BeNil())
+				report.Note("outcome: %s", outcome)
+				if outcome == util.Mutated {
+					report.Note("a mutating policy rewrote allowPrivilegeEscalation to false instead of denying the pod")
+				}
+				// the pod must not be admitted with privilege escalation allowed
+				Expect(outcome).NotTo(Equal(util.Admitted))
+			},
+			Entry("with allowPrivilegeEscalation true [privilege-escalation]", func() *bool {
+				allowPrivilegeEscalation := true
+				return &allowPrivilegeEscalation
+			}()),
+			Entry("with allowPrivilegeEscalation unset [privilege-escalation]", nil),
+		)
+	})
+
+	Context("disallowing privilege escalation", func() {
+
+		var podName = "nginx-no-new-privs-pod-test"
+
+		It("should run the container with no_new_privs set [privilege-escalation]", func() {
+			pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+			pod.Spec.RestartPolicy = v1.RestartPolicyNever
+			pod.Spec.Containers[0].Command = []string{"grep", "NoNewPrivs", "/proc/self/status"}
+			err := util.CreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+			Ω(err).Should(BeNil())
+
+			// wait up to 2 minutes for the container to print its status
+			logs, err := util.PodLogs(util.Context(), client.KubernetesClient, podName, util.TargetNamespace, 12)
+			if err != nil {
+				Fail(CurrentGinkgoTestDescription().TestText + ":" + err.Error())
+			}
+			// setuid binaries cannot gain privileges once no_new_privs is set
+			Expect(logs).To(MatchRegexp(`NoNewPrivs:\s+1`))
+		})
+
+		AfterEach(func() {
+			// delete the pod once the test is complete
+			err := util.DeletePod(util.Context(), client.KubernetesClient, podName, util.TargetNamespace)
+			if err != nil {
+				GinkgoT().Logf("%s Failed in teardown. %v:%d err: %v %s",
+					redColor,
+					CurrentGinkgoTestDescription().FileName,
+					CurrentGinkgoTestDescription().LineNumber,
+					err.Error(),
+					defaultStyle,
+				)
+			}
+		})
+	})
+})
@@ -10,8 +10,8 @@ import (
 	"github.com/yahoo/k8s-sec-check/util"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/api/extensions/v1beta1"
 )
 
 //Test case:
@@ -56,7 +56,7 @@ var _ = Describe("creating a deployment", func() {
 
 	Context("with Privileged container with capabilities", func() {
 
-		It("should return an error on creating of replicaset", func() {
+		It("should return an error on creating of replicaset [privileged] [capabilities]", func() {
 			// set the deployment with privilege true and replicacount and other linux capabilities
 			deployment := GetNginxDeploymentSpec(util.TargetNamespace, deploymentName, 1, true)
 			deployment.Spec.Template.Spec.Containers[0].SecurityContext.Capabilities = &v1.Capabilities{}
@@ -64,12 +64,12 @@ var _ = Describe("creating a deployment", func() {
 				Add = []v1.Capability{"NET_ADMIN", "NET_RAW", "SYS_PTRACE", "SYS_ADMIN", "KILL"}
 
 			// create deployment with above set configs
-			err := util.CreateDeployment(client.KubernetesClient, deployment, util.TargetNamespace)
+			err := util.CreateDeployment(util.Context(), client.KubernetesClient, deployment, util.TargetNamespace)
 			Ω(err).Should(BeNil())
 
 			// find replicaSet for the deployment by label
 			// retry 3 times, every 10 seconds
-			rsList, err := util.GetStatusCondition(client.KubernetesClient, deploymentName)
+			rsList, err := util.GetStatusCondition(util.Context(), client.KubernetesClient, deploymentName)
 			if err != nil {
 				Fail(CurrentGinkgoTestDescription().TestText + ":" + err.Error())
 			}
@@ -82,31 +82,23 @@ var _ = Describe("creating a deployment", func() {
 			// check if privileged container is failed to create with
 			// failure reason and condition
 			Expect(rsList.Items[0].Status.Conditions[0].Reason).To(Equal("FailedCreate"))
-			Expect(rsList.Items[0].Status.Conditions[0].Type).To(Equal(v1beta1.ReplicaSetReplicaFailure))
+			Expect(rsList.Items[0].Status.Conditions[0].Type).To(Equal(appsv1.ReplicaSetReplicaFailure))
 			Expect(rsList.Items[0].Status.Conditions[0].Status).To(Equal(v1.ConditionStatus("True")))
-			// assert if operation is forbidden and do not admit the operation
-			Expect(rsList.Items[0].Status.Conditions[0].Message).To(ContainSubstring("is forbidden: "))
-			// assert on Privileged container
-
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring("Privileged containers are not allowed"))
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring("capabilities.add: Invalid value: \"NET_ADMIN\": capability may not be added"))
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring("capabilities.add: Invalid value: \"NET_RAW\": capability may not be added"))
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring("capabilities.add: Invalid value: \"SYS_PTRACE\": capability may not be added"))
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring("capabilities.add: Invalid value: \"SYS_ADMIN\": capability may not be added"))
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring("capabilities.add: Invalid value: \"KILL\": capability may not be added"))
-
+			// assert if operation is forbidden, and on privileged container and
+			// each added capability
+			expectDenied(rsList.Items[0].Status.Conditions[0].Message,
+				"Privileged containers are not allowed",
+				"capabilities.add: Invalid value: \"NET_ADMIN\": capability may not be added",
+				"capabilities.add: Invalid value: \"NET_RAW\": capability may not be added",
+				"capabilities.add: Invalid value: \"SYS_PTRACE\": capability may not be added",
+				"capabilities.add: Invalid value: \"SYS_ADMIN\": capability may not be added",
+				"capabilities.add: Invalid value: \"KILL\": capability may not be added")
 		})
 	})
 
 	AfterEach(func() {
 		// delete the deployment once the test is complete
-		err := util.DeleteDeployment(client.KubernetesClient, deploymentName, util.TargetNamespace)
+		err := util.DeleteDeployment(util.Context(), client.KubernetesClient, deploymentName, util.TargetNamespace)
 		if err != nil {
 			GinkgoT().Logf("%s Failed in teardown. %v:%d err: %v %s",
 				redColor,
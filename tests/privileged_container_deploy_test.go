@@ -8,8 +8,8 @@ import (
 
 	"github.com/yahoo/k8s-sec-check/client"
 	"github.com/yahoo/k8s-sec-check/util"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/api/extensions/v1beta1"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -57,7 +57,8 @@ var _ = Describe("creating a deployment", func() {
 
 	Context("with Privileged container", func() {
 
-		It("should return an error on creating of replicaset", func() {
+		It("should return an error on creating of replicaset "+
+			"[privileged] [host-network] [host-pid] [host-ipc]", func() {
 			// set privileged container and host network, pid and ipc.
 			deployment := GetNginxDeploymentSpec(util.TargetNamespace, deploymentName, 1, true)
 			deployment.Spec.Template.Spec.HostNetwork = true
@@ -65,12 +66,12 @@ var _ = Describe("creating a deployment", func() {
 			deployment.Spec.Template.Spec.HostIPC = true
 
 			// create deployment with privilege true and replicacount set to 1
-			err := util.CreateDeployment(client.KubernetesClient, deployment, util.TargetNamespace)
+			err := util.CreateDeployment(util.Context(), client.KubernetesClient, deployment, util.TargetNamespace)
 			Ω(err).Should(BeNil())
 
 			// find replicaSet for the deployment by label
 			// retry 3 times, every 10 seconds
-			rsList, err := util.GetStatusCondition(client.KubernetesClient, deploymentName)
+			rsList, err := util.GetStatusCondition(util.Context(), client.KubernetesClient, deploymentName)
 			if err != nil {
 				Fail(CurrentGinkgoTestDescription().TestText + ":" + err.Error())
 			}
@@ -83,33 +84,22 @@ var _ = Describe("creating a deployment", func() {
 			// check if privileged container is failed to create with
 			// failure reason and condition
 			Expect(rsList.Items[0].Status.Conditions[0].Reason).To(Equal("FailedCreate"))
-			Expect(rsList.Items[0].Status.Conditions[0].Type).To(Equal(v1beta1.ReplicaSetReplicaFailure))
+			Expect(rsList.Items[0].Status.Conditions[0].Type).To(Equal(appsv1.ReplicaSetReplicaFailure))
 			Expect(rsList.Items[0].Status.Conditions[0].Status).To(Equal(v1.ConditionStatus("True")))
-			// assert on Host network
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring(
-					"spec.securityContext.hostNetwork: Invalid value: true: " +
-						"Host network is not allowed to be used"))
-			// assert on host PID
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring("spec.securityContext.hostPID: Invalid value: true: " +
-					"Host PID is not allowed to be used"))
-			// assert on host IPC
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring("spec.securityContext.hostIPC: Invalid value: true: " +
-					"Host IPC is not allowed to be used"))
-			// assert if operation is forbidden and do not admit the operation
-			Expect(rsList.Items[0].Status.Conditions[0].Message).To(ContainSubstring("is forbidden: "))
-			// assert on Privileged container
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring("spec.containers[0].securityContext.privileged: Invalid value: true: " +
-					"Privileged containers are not allowed"))
+			// assert if operation is forbidden, and on host network, PID, IPC
+			// and privileged container
+			expectDenied(rsList.Items[0].Status.Conditions[0].Message,
+				"spec.securityContext.hostNetwork: Invalid value: true: Host network is not allowed to be used",
+				"spec.securityContext.hostPID: Invalid value: true: Host PID is not allowed to be used",
+				"spec.securityContext.hostIPC: Invalid value: true: Host IPC is not allowed to be used",
+				"spec.containers[0].securityContext.privileged: Invalid value: true: "+
+					"Privileged containers are not allowed")
 		})
 	})
 
 	AfterEach(func() {
 		// delete the deployment once the test is complete
-		err := util.DeleteDeployment(client.KubernetesClient, deploymentName, util.TargetNamespace)
+		err := util.DeleteDeployment(util.Context(), client.KubernetesClient, deploymentName, util.TargetNamespace)
 		if err != nil {
 			GinkgoT().Logf("%s Failed in teardown. %v:%d err: %v %s",
 				redColor,
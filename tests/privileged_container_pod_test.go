@@ -36,36 +36,27 @@ var _ = Describe("creating a pod", func() {
 
 	Context("with Privileged security context", func() {
 
-		It("should return an error on creating pod", func() {
+		It("should return an error on creating pod [host-network] [host-pid] [host-ipc]", func() {
 			// create pod with privilege true
 			pod := GetNginxPodSpec(util.TargetNamespace, PodName, false)
 			pod.Spec.HostNetwork = true
 			pod.Spec.HostPID = true
 			pod.Spec.HostIPC = true
-			err := util.CreatePod(client.KubernetesClient, pod, util.TargetNamespace)
+			err := util.CreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
 			// assert for an existence of an error
 			Ω(err).ShouldNot(BeNil())
-			// assert on Host network
-			Expect(err.Error()).To(
-				ContainSubstring("spec.securityContext.hostNetwork: Invalid value: true: " +
-					"Host network is not allowed to be used"))
-			// assert on host PID
-			Expect(err.Error()).To(
-				ContainSubstring("spec.securityContext.hostPID: Invalid value: true: " +
-					"Host PID is not allowed to be used"))
-			// assert on host IPC
-			Expect(err.Error()).To(
-				ContainSubstring("spec.securityContext.hostIPC: Invalid value: true: " +
-					"Host IPC is not allowed to be used"))
-			// assert if operation is forbidden and do not admit the operation
-			Expect(err.Error()).To(ContainSubstring("is forbidden: "))
+			// assert if operation is forbidden, and on host network, PID and IPC
+			expectDenied(err.Error(),
+				"spec.securityContext.hostNetwork: Invalid value: true: Host network is not allowed to be used",
+				"spec.securityContext.hostPID: Invalid value: true: Host PID is not allowed to be used",
+				"spec.securityContext.hostIPC: Invalid value: true: Host IPC is not allowed to be used")
 		})
 	})
 
 	AfterEach(func() {
 		if CurrentGinkgoTestDescription().Failed {
 			// delete the pod once the test is complete, if test created the pod successfully
-			err := util.DeletePod(client.KubernetesClient, PodName, util.TargetNamespace)
+			err := util.DeletePod(util.Context(), client.KubernetesClient, PodName, util.TargetNamespace)
 			if err != nil {
 				GinkgoT().Logf("%s Failed in teardown. %v:%d err: %v %s",
 					redColor,
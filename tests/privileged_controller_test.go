@@ -0,0 +1,133 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+//Test case(s):
+//  Do not admit privileged containers through any workload controller
+
+//	Submit the same privileged PodTemplate, with host network, host PID and
+//	host IPC, through a DaemonSet, a StatefulSet, a Job, a CronJob, a
+//	ReplicationController and a ReplicaSet. Admission may deny the
+//	controller itself, or the pods it creates, in which case the controller
+//	reports the failure in its ReplicaFailure condition or a FailedCreate
+//	event. Assert that no pod gets created. This catches admission webhooks
+//	whose rules match Pods but not controllers, or the reverse.
+
+// https://kubernetes.io/docs/concepts/workloads/controllers/
+// https://kubernetes.io/docs/reference/access-authn-authz/extensible-admission-controllers/#matching-requests-rules
+
+//Sample error output:
+//	Warning  FailedCreate  daemonset-controller  Error creating: pods "nginx-privileged-controller-test-x7k2p"
+//	is forbidden: violates PodSecurity "baseline:latest": host namespaces (hostNetwork=true, hostPID=true,
+//	hostIPC=true), privileged (container "nginx" must not set securityContext.privileged=true)
+
+// privilegedController returns the controller of the given kind running the
+// privileged nginx pod template with the host namespaces
+func privilegedController(kind string, name string) runtime.Object {
+	obj := GetNginxControllerSpec(kind, util.TargetNamespace, name, true)
+	var spec *v1.PodSpec
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		spec = &o.Spec.Template.Spec
+	case *appsv1.ReplicaSet:
+		spec = &o.Spec.Template.Spec
+	case *v1.ReplicationController:
+		spec = &o.Spec.Template.Spec
+	case *appsv1.DaemonSet:
+		spec = &o.Spec.Template.Spec
+	case *appsv1.StatefulSet:
+		spec = &o.Spec.Template.Spec
+	case *batchv1.Job:
+		spec = &o.Spec.Template.Spec
+	case *batchv1.CronJob:
+		spec = &o.Spec.JobTemplate.Spec.Template.Spec
+	}
+	spec.HostNetwork = true
+	spec.HostPID = true
+	spec.HostIPC = true
+	return obj
+}
+
+var _ = Describe("creating a workload controller", func() {
+
+	var controllerName = "nginx-privileged-controller-test"
+	var controllerKind string
+
+	Context("with a privileged pod template", func() {
+
+		DescribeTable("should deny the controller or fail to create its pods",
+			func(kind string, retryCount int) {
+				controllerKind = kind
+				err := util.CreateController(util.Context(), client.KubernetesClient,
+					privilegedController(kind, controllerName), util.TargetNamespace)
+				if err != nil {
+					// denied before any pod is created, e.g. by a policy on controllers
+					Expect(util.IsAdmissionDenied(err)).To(BeTrue(), err.Error())
+					report.Note("%s denied: %s", kind, err.Error())
+					return
+				}
+
+				creation, err := util.GetPodCreation(util.Context(), client.KubernetesClient,
+					kind, controllerName, util.TargetNamespace, retryCount)
+				if err != nil {
+					Fail(CurrentGinkgoTestDescription().TestText + ": " + err.Error())
+				}
+				if creation.Created {
+					report.Record(report.Finding{
+						Check:    checks.Privileged,
+						Resource: kind + " " + util.TargetNamespace + "/" + controllerName,
+						Message:  "privileged pod with host namespaces created through a " + kind,
+					})
+				}
+				Expect(creation.Created).To(BeFalse(), "privileged pod created through a "+kind)
+				report.Note("%s pods denied: %s", kind, creation.FailureMessage)
+				// assert if operation is forbidden and do not admit the operation
+				expectDenied(creation.FailureMessage)
+			},
+			// retry every 10 seconds, for up to 2 minutes
+			Entry("through a DaemonSet [privileged] [host-network] [host-pid] [host-ipc]",
+				util.DaemonSetKind, 12),
+			Entry("through a StatefulSet [privileged] [host-network] [host-pid] [host-ipc]",
+				util.StatefulSetKind, 12),
+			Entry("through a Job [privileged] [host-network] [host-pid] [host-ipc]",
+				util.JobKind, 12),
+			// the first Job is only scheduled at the next minute
+			Entry("through a CronJob [privileged] [host-network] [host-pid] [host-ipc]",
+				util.CronJobKind, 18),
+			Entry("through a ReplicationController [privileged] [host-network] [host-pid] [host-ipc]",
+				util.ReplicationControllerKind, 12),
+			Entry("through a ReplicaSet [privileged] [host-network] [host-pid] [host-ipc]",
+				util.ReplicaSetKind, 12),
+		)
+	})
+
+	AfterEach(func() {
+		// delete the controller, and whatever it created, once the test is complete
+		err := util.DeleteController(util.Context(), client.KubernetesClient,
+			controllerKind, controllerName, util.TargetNamespace)
+		if err != nil {
+			GinkgoT().Logf("%s Failed in teardown. %v:%d err: %v %s",
+				redColor,
+				CurrentGinkgoTestDescription().FileName,
+				CurrentGinkgoTestDescription().LineNumber,
+				err.Error(),
+				defaultStyle,
+			)
+		}
+	})
+})
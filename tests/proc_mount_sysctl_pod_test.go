@@ -0,0 +1,143 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+//Test case(s):
+//  Do not admit containers with an unmasked /proc
+//  Do not admit pods setting unsafe sysctls
+//  Do not admit Windows HostProcess pods
+
+//	Create pods with an Unmasked procMount, with a sysctl of each unsafe
+//	class: namespaced kernel.* IPC sysctls, net.* sysctls outside of the
+//	safe set and node-level sysctls, and with hostProcess set in the pod or
+//	container windowsOptions, and assert that each is denied. Whether
+//	admission rejected each probe is noted in the report, and each admitted
+//	probe is recorded as a finding. HostProcess pods must also use the host
+//	network, so their denial only counts if it names hostProcess, and is
+//	otherwise noted and skipped.
+
+// https://kubernetes.io/docs/concepts/security/pod-security-standards/#baseline
+// https://kubernetes.io/docs/tasks/administer-cluster/sysctl-cluster/
+// https://kubernetes.io/docs/tasks/configure-pod-container/create-hostprocess-pod/
+
+//Sample error output:
+//	Failed to create pod: pods "nginx-proc-mount-sysctl-pod-test" is forbidden: violates PodSecurity
+//	"baseline:latest": forbidden sysctls (kernel.msgmax)
+
+// namesHostProcess reports whether the denial of a HostProcess probe names
+// hostProcess. Such probes also set hostNetwork to pass validation, so a
+// denial naming only hostNetwork does not show hostProcess is denied.
+func namesHostProcess(message string) bool {
+	message = strings.ToLower(message)
+	return strings.Contains(message, "hostprocess") || strings.Contains(message, "windowsoptions")
+}
+
+var _ = Describe("creating a pod", func() {
+
+	var podName = "nginx-proc-mount-sysctl-pod-test"
+
+	Context("with an unmasked /proc, an unsafe sysctl or hostProcess", func() {
+
+		DescribeTable("should return an error on creating pod",
+			func(id checks.ID, probe string, violate func(pod *v1.Pod)) {
+				pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+				violate(pod)
+				_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+				if err == nil {
+					report.Note("%s: admitted", probe)
+					report.Record(report.Finding{
+						Check:    id,
+						Resource: "Namespace " + util.TargetNamespace,
+						Message:  probe + " admitted",
+					})
+				} else if util.IsAdmissionDenied(err) {
+					if id == checks.HostProcess && !namesHostProcess(err.Error()) {
+						report.Note("%s: denied, cause indistinguishable from hostNetwork", probe)
+						Skip(probe + " denied, cause indistinguishable from hostNetwork")
+					}
+					report.Note("%s: denied", probe)
+				}
+				// assert for an existence of an error
+				Ω(err).ShouldNot(BeNil())
+				// assert if operation is forbidden and do not admit the operation
+				Expect(util.IsAdmissionDenied(err)).To(BeTrue(), err.Error())
+			},
+			Entry("with procMount Unmasked [proc-mount]", checks.ProcMount, "procMount Unmasked",
+				func(pod *v1.Pod) {
+					// an unmasked /proc is only valid in a user namespace
+					hostUsers := false
+					procMount := v1.UnmaskedProcMount
+					pod.Spec.HostUsers = &hostUsers
+					pod.Spec.Containers[0].SecurityContext.ProcMount = &procMount
+				}),
+			Entry("with the kernel.msgmax IPC sysctl [sysctls]", checks.Sysctls, "sysctl kernel.msgmax",
+				func(pod *v1.Pod) {
+					pod.Spec.SecurityContext.Sysctls = []v1.Sysctl{{Name: "kernel.msgmax", Value: "65536"}}
+				}),
+			Entry("with the kernel.shmmax IPC sysctl [sysctls]", checks.Sysctls, "sysctl kernel.shmmax",
+				func(pod *v1.Pod) {
+					pod.Spec.SecurityContext.Sysctls = []v1.Sysctl{{Name: "kernel.shmmax", Value: "68719476736"}}
+				}),
+			Entry("with the net.core.somaxconn sysctl [sysctls]", checks.Sysctls, "sysctl net.core.somaxconn",
+				func(pod *v1.Pod) {
+					pod.Spec.SecurityContext.Sysctls = []v1.Sysctl{{Name: "net.core.somaxconn", Value: "1024"}}
+				}),
+			Entry("with the net.ipv4.ip_forward sysctl [sysctls]", checks.Sysctls, "sysctl net.ipv4.ip_forward",
+				func(pod *v1.Pod) {
+					pod.Spec.SecurityContext.Sysctls = []v1.Sysctl{{Name: "net.ipv4.ip_forward", Value: "1"}}
+				}),
+			Entry("with the net.ipv4.conf.all.route_localnet sysctl [sysctls]", checks.Sysctls,
+				"sysctl net.ipv4.conf.all.route_localnet",
+				func(pod *v1.Pod) {
+					pod.Spec.SecurityContext.Sysctls = []v1.Sysctl{{Name: "net.ipv4.conf.all.route_localnet", Value: "1"}}
+				}),
+			Entry("with the node-level vm.swappiness sysctl [sysctls]", checks.Sysctls, "sysctl vm.swappiness",
+				func(pod *v1.Pod) {
+					pod.Spec.SecurityContext.Sysctls = []v1.Sysctl{{Name: "vm.swappiness", Value: "0"}}
+				}),
+			Entry("with hostProcess in the pod windowsOptions [host-process]", checks.HostProcess,
+				"pod windowsOptions.hostProcess",
+				func(pod *v1.Pod) {
+					// HostProcess pods must use the host network to pass validation
+					hostProcess := true
+					pod.Spec.HostNetwork = true
+					pod.Spec.SecurityContext.WindowsOptions = &v1.WindowsSecurityContextOptions{HostProcess: &hostProcess}
+				}),
+			Entry("with hostProcess in the container windowsOptions [host-process]", checks.HostProcess,
+				"container windowsOptions.hostProcess",
+				func(pod *v1.Pod) {
+					// every container of a HostProcess pod must be one
+					hostProcess := true
+					pod.Spec.HostNetwork = true
+					pod.Spec.Containers[0].SecurityContext.WindowsOptions = &v1.WindowsSecurityContextOptions{
+						HostProcess: &hostProcess,
+					}
+				}),
+		)
+	})
+
+	Context("with a safe sysctl", func() {
+
+		It("should admit the pod [sysctls]", func() {
+			Expect(checks.SysctlSafe("net.ipv4.ip_unprivileged_port_start")).To(BeTrue())
+			pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+			pod.Spec.SecurityContext.Sysctls = []v1.Sysctl{{Name: "net.ipv4.ip_unprivileged_port_start", Value: "1024"}}
+			_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+			Ω(err).Should(BeNil())
+		})
+	})
+})
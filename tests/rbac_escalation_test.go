@@ -0,0 +1,48 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/rbac"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+//Test case:
+//  No subject may escalate to cluster-admin-equivalent power through RBAC
+
+//	Read every ClusterRole, Role and binding, and build the escalation graph
+//	from each bound subject: escalate and bind on roles, impersonate,
+//	wildcard verbs and resources, create pods/exec, nodes/proxy,
+//	serviceaccounts/token, secrets get and list, and CSR approval. Each
+//	edge leads to a service account the subject can act as, or to
+//	cluster-admin. Assert that no subject, other than the identities
+//	Kubernetes components run as, has such a permission. The whole graph
+//	is written in DOT by the rbac command of k8s-sec-check.
+
+// https://kubernetes.io/docs/concepts/security/rbac-good-practices/#privilege-escalation-risks
+
+//Sample report:
+//	User alice -[exec into pods by RoleBinding/web/alice-debug]-> ServiceAccount web/deployer
+//	-[bind clusterroles by ClusterRoleBinding/deployer]-> cluster-admin
+
+var _ = Describe("reading the RBAC roles and bindings", func() {
+
+	Context("for escalation paths", func() {
+
+		It("should find no subject able to escalate [rbac-escalation]", func() {
+			snapshot, err := rbac.Load(util.Context(), client.KubernetesClient)
+			Ω(err).Should(BeNil())
+
+			findings := rbac.EscalationFindings(snapshot.EscalationGraph())
+			for _, finding := range findings {
+				report.Record(finding)
+			}
+			Expect(findings).To(BeEmpty())
+		})
+	})
+})
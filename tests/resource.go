@@ -5,10 +5,14 @@
 package tests
 
 import (
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
@@ -105,3 +109,116 @@ func GetNginxPodSpec(namespace string, podName string, privileged bool) *v1.Pod
 		},
 	}
 }
+
+// GetRestrictedNginxPodSpec returns the nginx pod spec complying with the
+// restricted Pod Security Standard, for checks that violate one control at a
+// time: every other field complies, so a denial can only come from the
+// control under test. The checks submit it with DryRunCreatePod, so probing
+// a control creates no pod.
+func GetRestrictedNginxPodSpec(namespace string, podName string) *v1.Pod {
+	runAsNonRoot := true
+	runAsUser := int64(1000)
+	allowPrivilegeEscalation := false
+
+	pod := GetNginxPodSpec(namespace, podName, false)
+	pod.Spec.SecurityContext = &v1.PodSecurityContext{
+		RunAsNonRoot: &runAsNonRoot,
+		RunAsUser:    &runAsUser,
+		SeccompProfile: &v1.SeccompProfile{
+			Type: v1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+	pod.Spec.Containers[0].SecurityContext.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+	pod.Spec.Containers[0].SecurityContext.Capabilities = &v1.Capabilities{
+		Drop: []v1.Capability{"ALL"},
+	}
+	return pod
+}
+
+// GetRestrictedNginxDeploymentSpec returns a deployment of the unprivileged
+// nginx image, listening on port 8080, whose pods comply with the
+// restricted Pod Security Standard, so it runs in namespaces enforcing it
+func GetRestrictedNginxDeploymentSpec(namespace string, deploymentName string) *appsv1.Deployment {
+	deployment := GetNginxDeploymentSpec(namespace, deploymentName, 1, false)
+	pod := GetRestrictedNginxPodSpec(namespace, deploymentName)
+	pod.Spec.Containers[0].Image = "nginxinc/nginx-unprivileged"
+	pod.Spec.Containers[0].Ports = []v1.ContainerPort{{ContainerPort: 8080, Protocol: v1.ProtocolTCP}}
+	deployment.Spec.Template.Spec = pod.Spec
+	return deployment
+}
+
+// GetNginxControllerSpec returns a workload controller of the given kind
+// running the nginx pod template of GetNginxDeploymentSpec, labelled
+// k8s-app=name so GetPodCreation can find its pods. It fails the spec on
+// any other kind.
+func GetNginxControllerSpec(kind string, namespace string, name string, privileged bool) runtime.Object {
+	replicaCount := int32(1)
+	backoffLimit := int32(0)
+	meta := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+	}
+	selector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			"k8s-app": name,
+		},
+	}
+	template := GetNginxDeploymentSpec(namespace, name, replicaCount, privileged).Spec.Template
+
+	switch kind {
+	case util.DeploymentKind:
+		return GetNginxDeploymentSpec(namespace, name, replicaCount, privileged)
+	case util.ReplicaSetKind:
+		return &appsv1.ReplicaSet{
+			ObjectMeta: meta,
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicaCount, Selector: selector, Template: template},
+		}
+	case util.ReplicationControllerKind:
+		return &v1.ReplicationController{
+			ObjectMeta: meta,
+			Spec: v1.ReplicationControllerSpec{
+				Replicas: &replicaCount,
+				Selector: selector.MatchLabels,
+				Template: &template,
+			},
+		}
+	case util.DaemonSetKind:
+		return &appsv1.DaemonSet{
+			ObjectMeta: meta,
+			Spec:       appsv1.DaemonSetSpec{Selector: selector, Template: template},
+		}
+	case util.StatefulSetKind:
+		return &appsv1.StatefulSet{
+			ObjectMeta: meta,
+			Spec: appsv1.StatefulSetSpec{
+				Replicas:    &replicaCount,
+				Selector:    selector,
+				ServiceName: name,
+				Template:    template,
+			},
+		}
+	}
+
+	// jobs may not restart their pods always, and need not retry
+	template.Spec.RestartPolicy = v1.RestartPolicyNever
+	jobSpec := batchv1.JobSpec{BackoffLimit: &backoffLimit, Template: template}
+	switch kind {
+	case util.JobKind:
+		return &batchv1.Job{ObjectMeta: meta, Spec: jobSpec}
+	case util.CronJobKind:
+		return &batchv1.CronJob{
+			ObjectMeta: meta,
+			Spec: batchv1.CronJobSpec{
+				Schedule:          "*/1 * * * *",
+				ConcurrencyPolicy: batchv1.ForbidConcurrent,
+				JobTemplate: batchv1.JobTemplateSpec{
+					// label the scheduled jobs so GetPodCreation can follow them
+					ObjectMeta: metav1.ObjectMeta{Labels: selector.MatchLabels},
+					Spec:       jobSpec,
+				},
+			},
+		}
+	}
+	Fail("GetNginxControllerSpec: unsupported controller kind " + kind)
+	return nil
+}
@@ -12,7 +12,6 @@ import (
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/api/extensions/v1beta1"
 )
 
 //Test case:
@@ -92,15 +91,16 @@ var _ = Describe("creating a deployment", func() {
 
 	Context("with Privileged container, host path and flex volumes", func() {
 
-		It("should return an error on creating of replicaset", func() {
+		It("should return an error on creating of replicaset "+
+			"[privileged] [host-path-volume] [flex-volume]", func() {
 
 			// create deployment with privilege true and set volumes
-			err := util.CreateDeployment(client.KubernetesClient, deployment, util.TargetNamespace)
+			err := util.CreateDeployment(util.Context(), client.KubernetesClient, deployment, util.TargetNamespace)
 			// error should be nil
 			Ω(err).Should(BeNil())
 
 			// once deployment is created, find replicaSet for the deployment by label
-			rsList, err := util.GetStatusCondition(client.KubernetesClient, deploymentName)
+			rsList, err := util.GetStatusCondition(util.Context(), client.KubernetesClient, deploymentName)
 			if err != nil {
 				Fail(CurrentGinkgoTestDescription().TestText + ":" + err.Error())
 			}
@@ -118,37 +118,23 @@ var _ = Describe("creating a deployment", func() {
 			// check if privileged container is failed to create with
 			// failure reason and condition
 			Expect(rsList.Items[0].Status.Conditions[0].Reason).To(Equal("FailedCreate"))
-			Expect(rsList.Items[0].Status.Conditions[0].Type).To(Equal(v1beta1.ReplicaSetReplicaFailure))
+			Expect(rsList.Items[0].Status.Conditions[0].Type).To(Equal(appsv1.ReplicaSetReplicaFailure))
 			Expect(rsList.Items[0].Status.Conditions[0].Status).To(Equal(v1.ConditionStatus("True")))
 
-			// assert on host path volume
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring(
-					"\"hostPath\": " + "hostPath volumes are not allowed to be used"))
-
-			// assert on flex volume
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring(
-					"\"flexVolume\": flexVolume volumes are not allowed to be used"))
-
-			// assert of flex volume driver
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring(
-					"\"kubernetes.io/lvm\": Flexvolume driver is not allowed to be used"))
-
-			// assert if operation is forbidden and do not admit the operation
-			Expect(rsList.Items[0].Status.Conditions[0].Message).To(ContainSubstring("is forbidden: "))
-
-			// assert on Privileged container
-			Expect(rsList.Items[0].Status.Conditions[0].Message).
-				To(ContainSubstring("spec.containers[0].securityContext.privileged: Invalid value: true: " +
-					"Privileged containers are not allowed"))
+			// assert if operation is forbidden, and on host path volume, flex
+			// volume, flex volume driver and privileged container
+			expectDenied(rsList.Items[0].Status.Conditions[0].Message,
+				"\"hostPath\": hostPath volumes are not allowed to be used",
+				"\"flexVolume\": flexVolume volumes are not allowed to be used",
+				"\"kubernetes.io/lvm\": Flexvolume driver is not allowed to be used",
+				"spec.containers[0].securityContext.privileged: Invalid value: true: "+
+					"Privileged containers are not allowed")
 		})
 	})
 
 	AfterEach(func() {
 		// delete the deployment once the test is complete
-		err := util.DeleteDeployment(client.KubernetesClient, deploymentName, util.TargetNamespace)
+		err := util.DeleteDeployment(util.Context(), client.KubernetesClient, deploymentName, util.TargetNamespace)
 		if err != nil {
 			GinkgoT().Logf("%s Failed in teardown. %v:%d err: %v %s",
 				redColor,
@@ -0,0 +1,71 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+//Test case(s):
+//  Do not admit containers running as user 0
+//  Do not admit containers that may run as root
+
+//	Create pods with runAsUser set to 0, with runAsNonRoot set to false, and
+//	with neither set on the nginx image, whose default user is root, at both
+//	the pod and the container securityContext level. Assert that each is
+//	denied under the restricted policy.
+
+// https://kubernetes.io/docs/concepts/security/pod-security-standards/#restricted
+// https://kubernetes.io/docs/tasks/configure-pod-container/security-context/
+
+//Sample error output:
+//	Failed to create pod: pods "nginx-run-as-root-pod-test" is forbidden: violates PodSecurity
+//	"restricted:latest": runAsNonRoot != true (pod must not set securityContext.runAsNonRoot=false),
+//	runAsUser=0 (pod must not set runAsUser=0)
+
+var _ = Describe("creating a pod", func() {
+
+	var podName = "nginx-run-as-root-pod-test"
+
+	Context("running as root", func() {
+
+		DescribeTable("should return an error on creating pod",
+			func(runAsRoot func(pod *v1.Pod)) {
+				pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+				runAsRoot(pod)
+				_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+				// assert for an existence of an error
+				Ω(err).ShouldNot(BeNil())
+				// assert if operation is forbidden and do not admit the operation
+				Expect(util.IsAdmissionDenied(err)).To(BeTrue(), err.Error())
+			},
+			Entry("with runAsUser 0 in the pod securityContext [run-as-user]", func(pod *v1.Pod) {
+				runAsUser := int64(0)
+				pod.Spec.SecurityContext.RunAsUser = &runAsUser
+			}),
+			Entry("with runAsUser 0 in the container securityContext [run-as-user]", func(pod *v1.Pod) {
+				runAsUser := int64(0)
+				pod.Spec.Containers[0].SecurityContext.RunAsUser = &runAsUser
+			}),
+			Entry("with runAsNonRoot false in the pod securityContext [run-as-non-root]", func(pod *v1.Pod) {
+				runAsNonRoot := false
+				pod.Spec.SecurityContext.RunAsNonRoot = &runAsNonRoot
+			}),
+			Entry("with runAsNonRoot false in the container securityContext [run-as-non-root]", func(pod *v1.Pod) {
+				// the container level overrides runAsNonRoot true in the pod securityContext
+				runAsNonRoot := false
+				pod.Spec.Containers[0].SecurityContext.RunAsNonRoot = &runAsNonRoot
+			}),
+			Entry("with neither runAsUser nor runAsNonRoot set on a root image [run-as-non-root]", func(pod *v1.Pod) {
+				pod.Spec.SecurityContext.RunAsNonRoot = nil
+				pod.Spec.SecurityContext.RunAsUser = nil
+			}),
+		)
+	})
+})
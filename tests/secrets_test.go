@@ -0,0 +1,146 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"strings"
+
+	"github.com/yahoo/k8s-sec-check/audit"
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/rbac"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//Test case(s):
+//  Minimize access to secrets (CIS 5.1.2)
+//  Prefer using secrets as files over secrets as environment variables (CIS 5.4.1)
+//  Do not mount secrets into privileged pods
+//  The checking identity must not read secrets outside of its namespace
+
+//	Read the ClusterRoleBindings and assert that no subject outside of
+//	KUBE_ALLOWED_ADMIN_SUBJECTS and KUBE_ALLOWED_SECRET_READERS may get, list
+//	or watch secrets in all namespaces. Audit the workloads of every
+//	namespace but KUBE_SYSTEM_NAMESPACES, whose network and storage agents
+//	need both, and assert that none reads a secret from an environment
+//	variable, and that no privileged pod, or pod sharing a host namespace,
+//	mounts a secret. Then, as the identity the tool runs as, list the
+//	secrets of every namespace other than the target namespace, and assert
+//	that each request is refused. Each namespace whose secrets could be
+//	read is reported with the RBAC bindings granting it.
+
+// https://kubernetes.io/docs/concepts/security/secrets-good-practices/
+// https://kubernetes.io/docs/concepts/configuration/secret/#using-secrets-as-environment-variables
+
+//Sample report:
+//	User alice (owner: ClusterRoleBinding/auditors) can get, list, watch secrets in all namespaces through ClusterRole/auditor
+//	Deployment web/nginx spec.template.spec.containers[0].env[0].valueFrom.secretKeyRef: exposes key password of Secret db as environment variable DB_PASSWORD
+//	Namespace kube-system can list secrets as cd-user by ClusterRoleBinding/auditors (ClusterRole/auditor)
+
+// secretReadGrants names the bindings letting self list the secrets of namespace
+func secretReadGrants(namespace string) string {
+	self, err := rbac.Self(util.Context(), client.KubernetesClient)
+	if err != nil {
+		return "unknown, " + err.Error()
+	}
+	snapshot, err := rbac.Load(util.Context(), client.KubernetesClient)
+	if err != nil {
+		return self.Name + " by unknown, " + err.Error()
+	}
+	var bindings []string
+	for _, g := range snapshot.Grants(self, rbac.Attributes{Verb: "list", Resource: "secrets", Namespace: namespace}) {
+		bindings = append(bindings, g.String())
+	}
+	if len(bindings) == 0 {
+		// e.g. system:masters, or an authorizer other than RBAC
+		bindings = append(bindings, "no RBAC binding")
+	}
+	return self.Name + " by " + strings.Join(bindings, ", ")
+}
+
+// probedNamespaces returns every namespace, or the namespaces every
+// cluster has if the checking identity may not list them
+func probedNamespaces() []string {
+	namespaces, err := client.KubernetesClient.CoreV1().Namespaces().List(util.Context(), metav1.ListOptions{})
+	if err != nil {
+		report.Note("Failed to list namespaces, probing the default ones: %v", err)
+		return []string{"default", "kube-system", "kube-public", "kube-node-lease"}
+	}
+	var names []string
+	for _, ns := range namespaces.Items {
+		names = append(names, ns.Name)
+	}
+	return names
+}
+
+var _ = Describe("auditing the access to secrets", func() {
+
+	Context("granted by RBAC", func() {
+
+		It("should only let the allowed subjects read secrets in all namespaces [secret-access]", func() {
+			snapshot, err := rbac.Load(util.Context(), client.KubernetesClient)
+			Ω(err).Should(BeNil())
+
+			allowed := append(append([]string{}, util.AllowedAdminSubjects...), util.AllowedSecretReaders...)
+			findings := rbac.SecretReaderFindings(snapshot.SecretReaders(), allowed)
+			for _, finding := range findings {
+				report.Record(finding)
+			}
+			Expect(findings).To(BeEmpty())
+		})
+	})
+
+	Context("consumed by the workloads of every namespace", func() {
+
+		DescribeTable("should find nothing",
+			func(id checks.ID) {
+				findings, err := audit.Secrets(util.Context(), client.KubernetesClient, "", util.SystemNamespaces)
+				Ω(err).Should(BeNil())
+
+				var found []report.Finding
+				for _, finding := range findings {
+					if finding.Check == id {
+						report.Record(finding)
+						found = append(found, finding)
+					}
+				}
+				Expect(found).To(BeEmpty())
+			},
+			Entry("exposed as environment variables [secret-env-vars]", checks.SecretEnvVars),
+			Entry("mounted into privileged pods [privileged-secret-mounts]", checks.PrivilegedSecretMounts),
+		)
+	})
+
+	Context("of the other namespaces", func() {
+
+		It("should not be readable by the checking identity [secret-read-probe]", func() {
+			var readable []string
+			for _, namespace := range probedNamespaces() {
+				if namespace == util.TargetNamespace {
+					continue
+				}
+				_, err := client.KubernetesClient.CoreV1().Secrets(namespace).
+					List(util.Context(), metav1.ListOptions{Limit: 1})
+				// a namespace deleted since it was listed has nothing to read
+				if kerr.IsForbidden(err) || kerr.IsNotFound(err) {
+					continue
+				}
+				Ω(err).Should(BeNil(), namespace)
+				report.Record(report.Finding{
+					Check:    checks.SecretReadProbe,
+					Resource: "Namespace " + namespace,
+					Message:  "can list secrets as " + secretReadGrants(namespace),
+				})
+				readable = append(readable, namespace)
+			}
+			Expect(readable).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,141 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+//Test case(s):
+//  Do not admit containers without the RuntimeDefault or a Localhost seccomp profile
+//  Do not admit containers overriding the default AppArmor profile
+//  Do not admit containers setting a custom SELinux type, user or role
+
+//	Create pods with an Unconfined or a custom Localhost seccomp profile, an
+//	unconfined AppArmor profile set through the annotation or the field, and
+//	the spc_t SELinux type or a custom SELinux user or role, at pod and
+//	container level, and assert that each is denied. Note that the Pod
+//	Security Standards allow Localhost seccomp profiles, so denying them
+//	takes a stricter policy. Also assert that a pod with no seccomp profile
+//	is denied or defaulted to RuntimeDefault.
+
+// https://kubernetes.io/docs/concepts/security/pod-security-standards/#baseline
+// https://kubernetes.io/docs/tutorials/security/seccomp/
+// https://kubernetes.io/docs/tutorials/security/apparmor/
+// https://kubernetes.io/docs/tasks/configure-pod-container/security-context/#assign-selinux-labels-to-a-container
+
+//Sample error output:
+//	Failed to create pod: pods "nginx-security-profile-pod-test" is forbidden: violates PodSecurity
+//	"restricted:latest": seccompProfile (pod must not set securityContext.seccompProfile.type to "Unconfined")
+
+// hasRuntimeDefaultSeccomp reports whether every container of the pod runs
+// with the RuntimeDefault or a Localhost seccomp profile
+func hasRuntimeDefaultSeccomp(pod *v1.Pod) bool {
+	confined := func(p *v1.SeccompProfile) bool {
+		return p != nil && (p.Type == v1.SeccompProfileTypeRuntimeDefault || p.Type == v1.SeccompProfileTypeLocalhost)
+	}
+	podConfined := pod.Spec.SecurityContext != nil && confined(pod.Spec.SecurityContext.SeccompProfile)
+	for _, c := range pod.Spec.Containers {
+		// the container profile overrides the pod one
+		if c.SecurityContext != nil && c.SecurityContext.SeccompProfile != nil {
+			if !confined(c.SecurityContext.SeccompProfile) {
+				return false
+			}
+		} else if !podConfined {
+			return false
+		}
+	}
+	return true
+}
+
+var _ = Describe("creating a pod", func() {
+
+	var podName = "nginx-security-profile-pod-test"
+
+	Context("with a weakened kernel hardening profile", func() {
+
+		DescribeTable("should return an error on creating pod",
+			func(weaken func(pod *v1.Pod)) {
+				pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+				weaken(pod)
+				_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+				// assert for an existence of an error
+				Ω(err).ShouldNot(BeNil())
+				// assert if operation is forbidden and do not admit the operation
+				Expect(util.IsAdmissionDenied(err)).To(BeTrue(), err.Error())
+			},
+			Entry("with seccompProfile Unconfined in the pod securityContext [seccomp]", func(pod *v1.Pod) {
+				pod.Spec.SecurityContext.SeccompProfile = &v1.SeccompProfile{Type: v1.SeccompProfileTypeUnconfined}
+			}),
+			Entry("with seccompProfile Unconfined in the container securityContext [seccomp]", func(pod *v1.Pod) {
+				pod.Spec.Containers[0].SecurityContext.SeccompProfile = &v1.SeccompProfile{
+					Type: v1.SeccompProfileTypeUnconfined,
+				}
+			}),
+			Entry("with a custom Localhost seccompProfile in the pod securityContext [seccomp]", func(pod *v1.Pod) {
+				localhostProfile := "profiles/custom.json"
+				pod.Spec.SecurityContext.SeccompProfile = &v1.SeccompProfile{
+					Type:             v1.SeccompProfileTypeLocalhost,
+					LocalhostProfile: &localhostProfile,
+				}
+			}),
+			Entry("with a custom Localhost seccompProfile in the container securityContext [seccomp]", func(pod *v1.Pod) {
+				localhostProfile := "profiles/custom.json"
+				pod.Spec.Containers[0].SecurityContext.SeccompProfile = &v1.SeccompProfile{
+					Type:             v1.SeccompProfileTypeLocalhost,
+					LocalhostProfile: &localhostProfile,
+				}
+			}),
+			Entry("with the unconfined AppArmor annotation [apparmor]", func(pod *v1.Pod) {
+				key := v1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix + pod.Spec.Containers[0].Name
+				pod.Annotations = map[string]string{key: v1.DeprecatedAppArmorBetaProfileNameUnconfined}
+			}),
+			Entry("with appArmorProfile Unconfined in the pod securityContext [apparmor]", func(pod *v1.Pod) {
+				pod.Spec.SecurityContext.AppArmorProfile = &v1.AppArmorProfile{Type: v1.AppArmorProfileTypeUnconfined}
+			}),
+			Entry("with appArmorProfile Unconfined in the container securityContext [apparmor]", func(pod *v1.Pod) {
+				pod.Spec.Containers[0].SecurityContext.AppArmorProfile = &v1.AppArmorProfile{
+					Type: v1.AppArmorProfileTypeUnconfined,
+				}
+			}),
+			Entry("with SELinux type spc_t in the pod securityContext [selinux]", func(pod *v1.Pod) {
+				pod.Spec.SecurityContext.SELinuxOptions = &v1.SELinuxOptions{Type: "spc_t"}
+			}),
+			Entry("with SELinux type spc_t in the container securityContext [selinux]", func(pod *v1.Pod) {
+				pod.Spec.Containers[0].SecurityContext.SELinuxOptions = &v1.SELinuxOptions{Type: "spc_t"}
+			}),
+			Entry("with a custom SELinux user [selinux]", func(pod *v1.Pod) {
+				pod.Spec.Containers[0].SecurityContext.SELinuxOptions = &v1.SELinuxOptions{User: "system_u"}
+			}),
+			Entry("with a custom SELinux role [selinux]", func(pod *v1.Pod) {
+				pod.Spec.Containers[0].SecurityContext.SELinuxOptions = &v1.SELinuxOptions{Role: "system_r"}
+			}),
+		)
+	})
+
+	Context("with no seccomp profile", func() {
+
+		It("should return an error on creating pod, or default it to RuntimeDefault [seccomp]", func() {
+			pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+			pod.Spec.SecurityContext.SeccompProfile = nil
+
+			outcome, _, err := util.ProbePod(util.Context(), client.KubernetesClient, pod,
+				util.TargetNamespace, hasRuntimeDefaultSeccomp)
+			Ω(err).Should(BeNil())
+			report.Note("outcome: %s", outcome)
+			if outcome == util.Admitted {
+				// the kubelet may still apply RuntimeDefault with --seccomp-default,
+				// which cannot be seen from the API
+				report.Note("admitted without a seccomp profile, confinement depends on the kubelet seccomp default")
+			}
+			Expect(outcome).NotTo(Equal(util.Admitted))
+		})
+	})
+})
@@ -0,0 +1,63 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"github.com/yahoo/k8s-sec-check/audit"
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+//Test case(s):
+//  Ensure that default service accounts are not actively used (CIS 5.1.5)
+//  Ensure that Service Account Tokens are only mounted where necessary (CIS 5.1.6)
+//  No long-lived service account token Secrets may remain
+
+//	Audit the service accounts and the workloads of every namespace but
+//	KUBE_SYSTEM_NAMESPACES, whose default service accounts automount their
+//	token on every cluster, and assert that no default service account is
+//	bound to a role or automounts its token, that no workload runs as the
+//	default service account, that no service account or workload
+//	automounts a token which no RBAC binding grants anything, and that no
+//	Secret of type kubernetes.io/service-account-token remains.
+
+// https://kubernetes.io/docs/tasks/configure-pod-container/configure-service-account/#opt-out-of-api-credential-automounting
+// https://kubernetes.io/docs/concepts/security/service-accounts/#get-a-token
+
+//Sample report:
+//	Deployment web/nginx spec.template.spec.serviceAccountName: runs as the default service account
+//	Secret web/deployer-token-x2x9 long-lived token of ServiceAccount web/deployer
+
+var _ = Describe("auditing the service accounts", func() {
+
+	Context("of every namespace", func() {
+
+		DescribeTable("should find nothing",
+			func(id checks.ID) {
+				findings, err := audit.ServiceAccounts(util.Context(), client.KubernetesClient, "", util.SystemNamespaces)
+				Ω(err).Should(BeNil())
+
+				var found []report.Finding
+				for _, finding := range findings {
+					if finding.Check == id {
+						report.Record(finding)
+						found = append(found, finding)
+					}
+				}
+				Expect(found).To(BeEmpty())
+			},
+			Entry("bound to or used as the default service account [default-service-account]",
+				checks.DefaultServiceAccount),
+			Entry("automounting unneeded tokens [service-account-token-mount]",
+				checks.ServiceAccountTokenMount),
+			Entry("in legacy token Secrets [legacy-token-secrets]",
+				checks.LegacyTokenSecrets),
+		)
+	})
+})
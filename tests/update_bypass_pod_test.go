@@ -0,0 +1,199 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"fmt"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+)
+
+//Test case(s):
+//  Do not admit privileged ephemeral containers into running pods
+//  Note the updates admitted on the mutable fields of running pods
+
+//	Create a pod complying with the restricted profile, then try to inject
+//	an ephemeral container through the ephemeralcontainers subresource, the
+//	way kubectl debug does, that is privileged, adds SYS_ADMIN, runs as
+//	root, allows privilege escalation or runs without seccomp. Assert that
+//	each injection is blocked, and report each admitted one as a finding.
+//	Then try to update the fields of the pod which stay mutable: swap its
+//	image for an unvetted one, relabel it and make it tolerate every taint.
+//	The security context of a running pod cannot be updated, and Pod
+//	Security admission lets all three updates through, so they are only
+//	noted, for policies of other engines to be checked against. Attempts
+//	are submitted with server-side dry-run.
+
+// https://kubernetes.io/docs/concepts/workloads/pods/ephemeral-containers/
+// https://kubernetes.io/docs/concepts/security/pod-security-admission/#exemptions
+
+//Sample error output:
+//	Failed to update ephemeral containers: pods "nginx-ephemeral-container-pod-test" is forbidden:
+//	violates PodSecurity "baseline:latest": privileged (container "debugger" must not set
+//	securityContext.privileged=true)
+
+// podAttempt is an attempt at weakening the security of a running pod
+type podAttempt struct {
+	name   string
+	weaken func(sc *v1.SecurityContext, pod *v1.Pod)
+}
+
+// ephemeralAttempts set the security context of the injected debug container
+var ephemeralAttempts = []podAttempt{
+	{"privileged", func(sc *v1.SecurityContext, pod *v1.Pod) {
+		privileged := true
+		sc.Privileged = &privileged
+		sc.AllowPrivilegeEscalation = nil
+	}},
+	{"adding SYS_ADMIN", func(sc *v1.SecurityContext, pod *v1.Pod) {
+		sc.Capabilities = &v1.Capabilities{Add: []v1.Capability{"SYS_ADMIN"}}
+	}},
+	{"running as root", func(sc *v1.SecurityContext, pod *v1.Pod) {
+		runAsUser := int64(0)
+		runAsNonRoot := false
+		sc.RunAsUser = &runAsUser
+		sc.RunAsNonRoot = &runAsNonRoot
+	}},
+	{"allowing privilege escalation", func(sc *v1.SecurityContext, pod *v1.Pod) {
+		allowPrivilegeEscalation := true
+		sc.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+	}},
+	{"with seccompProfile Unconfined", func(sc *v1.SecurityContext, pod *v1.Pod) {
+		sc.SeccompProfile = &v1.SeccompProfile{Type: v1.SeccompProfileTypeUnconfined}
+	}},
+}
+
+// updateAttempts rewrite the fields of the running pod which validation
+// lets change: the container images, the labels and added tolerations.
+// Updates of the security context, or of the AppArmor annotations, are
+// always rejected by validation and would prove nothing about admission.
+// None of them weakens the pod security the standards check, so admitting
+// them is no finding.
+var updateAttempts = []podAttempt{
+	{"swapping the image for an unvetted one", func(sc *v1.SecurityContext, pod *v1.Pod) {
+		pod.Spec.Containers[0].Image = "docker.io/library/busybox:latest"
+	}},
+	{"relabelling the pod, which can move it out of NetworkPolicy selection", func(sc *v1.SecurityContext, pod *v1.Pod) {
+		pod.Labels["k8s-sec-check/relabelled"] = "true"
+	}},
+	{"tolerating every taint", func(sc *v1.SecurityContext, pod *v1.Pod) {
+		// tolerations may be added to a running pod, and keep it on nodes
+		// tainted NoExecute, e.g. to quarantine them
+		pod.Spec.Tolerations = append(pod.Spec.Tolerations, v1.Toleration{Operator: v1.TolerationOpExists})
+	}},
+}
+
+// restrictedDebugContainer returns an ephemeral container complying with
+// the restricted profile, targeting the nginx container
+func restrictedDebugContainer(name string) v1.EphemeralContainer {
+	allowPrivilegeEscalation := false
+	return v1.EphemeralContainer{
+		EphemeralContainerCommon: v1.EphemeralContainerCommon{
+			Name:            name,
+			Image:           "busybox",
+			ImagePullPolicy: v1.PullIfNotPresent,
+			Command:         []string{"sleep", "3600"},
+			SecurityContext: &v1.SecurityContext{
+				AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+				Capabilities:             &v1.Capabilities{Drop: []v1.Capability{"ALL"}},
+			},
+		},
+		TargetContainerName: "nginx",
+	}
+}
+
+// attemptBlocked reports whether the attempt was blocked, and notes by what.
+// A rejection by validation only counts as blocking if validated is set,
+// otherwise it means the attempt itself is broken. It fails the spec on any
+// other error.
+func attemptBlocked(attempt string, err error, validated bool) bool {
+	switch {
+	case err == nil:
+		report.Note("%s: admitted", attempt)
+		return false
+	case util.IsAdmissionDenied(err):
+		report.Note("%s: denied by admission", attempt)
+	case validated && kerr.IsInvalid(err):
+		report.Note("%s: rejected by validation", attempt)
+	default:
+		Fail(CurrentGinkgoTestDescription().TestText + ": " + err.Error())
+	}
+	return true
+}
+
+var _ = Describe("updating a running pod", func() {
+
+	var podName string
+
+	Context("injecting an ephemeral container", func() {
+
+		It("should return an error on each privileged ephemeral container [ephemeral-containers]", func() {
+			podName = "nginx-ephemeral-container-pod-test"
+			pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+			err := util.CreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+			Ω(err).Should(BeNil())
+
+			var admitted []string
+			for i, attempt := range ephemeralAttempts {
+				pod, err := util.GetPod(util.Context(), client.KubernetesClient, podName, util.TargetNamespace)
+				Ω(err).Should(BeNil())
+				debugger := restrictedDebugContainer(fmt.Sprintf("debugger-%d", i))
+				attempt.weaken(debugger.SecurityContext, pod)
+				pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, debugger)
+
+				_, err = util.DryRunUpdateEphemeralContainers(util.Context(), client.KubernetesClient,
+					pod, util.TargetNamespace)
+				if !attemptBlocked("ephemeral container "+attempt.name, err, true) {
+					admitted = append(admitted, attempt.name)
+					report.Record(report.Finding{
+						Check:    checks.EphemeralContainers,
+						Resource: "Namespace " + util.TargetNamespace,
+						Message:  "ephemeral container " + attempt.name + " admitted into a running pod",
+					})
+				}
+			}
+			Expect(admitted).To(BeEmpty())
+		})
+	})
+
+	Context("weakening the pod security", func() {
+
+		It("should note the updates admitted on a running pod [pod-update]", func() {
+			podName = "nginx-update-bypass-pod-test"
+			pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+			err := util.CreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+			Ω(err).Should(BeNil())
+
+			for _, attempt := range updateAttempts {
+				pod, err := util.GetPod(util.Context(), client.KubernetesClient, podName, util.TargetNamespace)
+				Ω(err).Should(BeNil())
+				attempt.weaken(pod.Spec.Containers[0].SecurityContext, pod)
+
+				_, err = util.DryRunUpdatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+				attemptBlocked("update "+attempt.name, err, false)
+			}
+		})
+	})
+
+	AfterEach(func() {
+		// delete the pod once the test is complete
+		err := util.DeletePod(util.Context(), client.KubernetesClient, podName, util.TargetNamespace)
+		if err != nil {
+			GinkgoT().Logf("%s Failed in teardown. %v:%d err: %v %s",
+				redColor,
+				CurrentGinkgoTestDescription().FileName,
+				CurrentGinkgoTestDescription().LineNumber,
+				err.Error(),
+				defaultStyle,
+			)
+		}
+	})
+})
@@ -0,0 +1,142 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package tests
+
+import (
+	"fmt"
+
+	"github.com/yahoo/k8s-sec-check/checks"
+	"github.com/yahoo/k8s-sec-check/client"
+	"github.com/yahoo/k8s-sec-check/report"
+	"github.com/yahoo/k8s-sec-check/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+)
+
+//Test case(s):
+//  Only admit the volume types allowed by the restricted profile
+
+//	Create an unprivileged pod with one volume at a time, for every
+//	VolumeSource type, and record whether admission admitted or denied it
+//	next to the restricted profile allow-list: configMap, csi, downwardAPI,
+//	emptyDir, ephemeral, persistentVolumeClaim, projected and secret. Types
+//	the API server no longer validates, e.g. removed in-tree plugins, are
+//	recorded as invalid.
+
+// https://kubernetes.io/docs/concepts/storage/volumes/#volume-types
+// https://kubernetes.io/docs/concepts/security/pod-security-standards/#restricted
+
+//Sample report:
+//	azureDisk              denied
+//	configMap              admitted
+//	nfs                    admitted   (restricted: denied)
+
+// probeVolume is a minimal valid volume of one VolumeSource type
+type probeVolume struct {
+	volumeType string
+	source     v1.VolumeSource
+}
+
+// probeVolumes returns a probe volume of every VolumeSource type
+func probeVolumes() []probeVolume {
+	lun := int32(0)
+	return []probeVolume{
+		{"awsElasticBlockStore", v1.VolumeSource{AWSElasticBlockStore: &v1.AWSElasticBlockStoreVolumeSource{VolumeID: "vol-probe"}}},
+		{"azureDisk", v1.VolumeSource{AzureDisk: &v1.AzureDiskVolumeSource{
+			DiskName: "probe", DataDiskURI: "https://probe.blob.core.windows.net/vhds/probe.vhd"}}},
+		{"azureFile", v1.VolumeSource{AzureFile: &v1.AzureFileVolumeSource{SecretName: "probe", ShareName: "probe"}}},
+		{"cephfs", v1.VolumeSource{CephFS: &v1.CephFSVolumeSource{Monitors: []string{"10.0.0.1:6789"}}}},
+		{"cinder", v1.VolumeSource{Cinder: &v1.CinderVolumeSource{VolumeID: "probe"}}},
+		{"configMap", v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{
+			LocalObjectReference: v1.LocalObjectReference{Name: "probe"}}}},
+		{"csi", v1.VolumeSource{CSI: &v1.CSIVolumeSource{Driver: "csi.example.com"}}},
+		{"downwardAPI", v1.VolumeSource{DownwardAPI: &v1.DownwardAPIVolumeSource{Items: []v1.DownwardAPIVolumeFile{
+			{Path: "labels", FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.labels"}}}}}},
+		{"emptyDir", v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+		{"ephemeral", v1.VolumeSource{Ephemeral: &v1.EphemeralVolumeSource{
+			VolumeClaimTemplate: &v1.PersistentVolumeClaimTemplate{Spec: v1.PersistentVolumeClaimSpec{
+				AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+				Resources: v1.VolumeResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+			}}}}},
+		{"fc", v1.VolumeSource{FC: &v1.FCVolumeSource{TargetWWNs: []string{"500a0982991b8dc5"}, Lun: &lun}}},
+		{"flexVolume", v1.VolumeSource{FlexVolume: &v1.FlexVolumeSource{Driver: "example.com/probe"}}},
+		{"flocker", v1.VolumeSource{Flocker: &v1.FlockerVolumeSource{DatasetName: "probe"}}},
+		{"gcePersistentDisk", v1.VolumeSource{GCEPersistentDisk: &v1.GCEPersistentDiskVolumeSource{PDName: "probe"}}},
+		{"gitRepo", v1.VolumeSource{GitRepo: &v1.GitRepoVolumeSource{Repository: "https://example.com/probe.git"}}},
+		{"glusterfs", v1.VolumeSource{Glusterfs: &v1.GlusterfsVolumeSource{EndpointsName: "probe", Path: "probe"}}},
+		{"hostPath", v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/tmp"}}},
+		{"image", v1.VolumeSource{Image: &v1.ImageVolumeSource{Reference: "busybox"}}},
+		{"iscsi", v1.VolumeSource{ISCSI: &v1.ISCSIVolumeSource{
+			TargetPortal: "10.0.0.1:3260", IQN: "iqn.2001-04.com.example:probe", Lun: 0}}},
+		{"nfs", v1.VolumeSource{NFS: &v1.NFSVolumeSource{Server: "nfs.example.com", Path: "/"}}},
+		{"persistentVolumeClaim", v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+			ClaimName: "probe"}}},
+		{"photonPersistentDisk", v1.VolumeSource{PhotonPersistentDisk: &v1.PhotonPersistentDiskVolumeSource{PdID: "probe"}}},
+		{"portworxVolume", v1.VolumeSource{PortworxVolume: &v1.PortworxVolumeSource{VolumeID: "probe"}}},
+		{"projected", v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{Sources: []v1.VolumeProjection{
+			{ServiceAccountToken: &v1.ServiceAccountTokenProjection{Path: "token"}}}}}},
+		{"quobyte", v1.VolumeSource{Quobyte: &v1.QuobyteVolumeSource{Registry: "quobyte:7861", Volume: "probe"}}},
+		{"rbd", v1.VolumeSource{RBD: &v1.RBDVolumeSource{CephMonitors: []string{"10.0.0.1:6789"}, RBDImage: "probe"}}},
+		{"scaleIO", v1.VolumeSource{ScaleIO: &v1.ScaleIOVolumeSource{
+			Gateway: "https://scaleio.example.com", System: "probe", SecretRef: &v1.LocalObjectReference{Name: "probe"}}}},
+		{"secret", v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "probe"}}},
+		{"storageos", v1.VolumeSource{StorageOS: &v1.StorageOSVolumeSource{VolumeName: "probe"}}},
+		{"vsphereVolume", v1.VolumeSource{VsphereVolume: &v1.VsphereVirtualDiskVolumeSource{
+			VolumePath: "[datastore] volumes/probe.vmdk"}}},
+	}
+}
+
+var _ = Describe("creating a pod", func() {
+
+	var podName = "nginx-volume-types-pod-test"
+
+	Context("with a single volume of each type on an unprivileged container", func() {
+
+		It("should only admit the volume types allowed by the restricted profile [volume-types]", func() {
+			var admitted []string
+			for _, volume := range probeVolumes() {
+				pod := GetRestrictedNginxPodSpec(util.TargetNamespace, podName)
+				pod.Spec.Volumes = []v1.Volume{{Name: "probe", VolumeSource: volume.source}}
+
+				verdict := "admitted"
+				_, err := util.DryRunCreatePod(util.Context(), client.KubernetesClient, pod, util.TargetNamespace)
+				switch {
+				case err == nil:
+				case util.IsAdmissionDenied(err):
+					verdict = "denied"
+				case kerr.IsInvalid(err):
+					// e.g. a removed in-tree plugin, or a disabled feature gate
+					verdict = "invalid"
+				default:
+					Fail(CurrentGinkgoTestDescription().TestText + ": " + err.Error())
+				}
+
+				// one row of the admitted/denied table per volume type
+				row := fmt.Sprintf("%-22s %s", volume.volumeType, verdict)
+				expected := checks.VolumeTypeAllowed(volume.volumeType)
+				if verdict == "admitted" && !expected {
+					row += "   (restricted: denied)"
+				} else if verdict == "denied" && expected {
+					row += "   (restricted: admitted)"
+				}
+				report.Note("%s", row)
+
+				if verdict == "admitted" && !expected {
+					admitted = append(admitted, volume.volumeType)
+					report.Record(report.Finding{
+						Check:    checks.VolumeTypes,
+						Resource: "Namespace " + util.TargetNamespace,
+						Message:  volume.volumeType + " volumes may be used",
+					})
+				}
+			}
+			Expect(admitted).To(BeEmpty())
+		})
+	})
+})
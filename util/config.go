@@ -0,0 +1,97 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package util
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AllowedHostPaths represents the hostPath prefixes the cluster policy
+// admits read-only, from KUBE_ALLOWED_HOST_PATHS
+var AllowedHostPaths = getList("KUBE_ALLOWED_HOST_PATHS")
+
+// AllowedHostPorts represents the host port ranges the cluster policy
+// admits, from KUBE_ALLOWED_HOST_PORTS, e.g. "8000-8080,9100"
+var AllowedHostPorts = getPortRanges("KUBE_ALLOWED_HOST_PORTS")
+
+// AllowedAdminSubjects represents the subjects expected to be bound to
+// cluster-admin or wildcard roles, from KUBE_ALLOWED_ADMIN_SUBJECTS, e.g.
+// "Group platform-admins,ServiceAccount flux-system/flux"
+var AllowedAdminSubjects = getList("KUBE_ALLOWED_ADMIN_SUBJECTS")
+
+// AllowedSecretReaders represents the subjects expected to read secrets in
+// all namespaces, besides AllowedAdminSubjects, from
+// KUBE_ALLOWED_SECRET_READERS, e.g. "ServiceAccount vault/vault"
+var AllowedSecretReaders = getList("KUBE_ALLOWED_SECRET_READERS")
+
+// SystemNamespaces represents the namespaces of the cluster components,
+// left out of the network policy, service account and secret checks and
+// which admission webhooks may exclude, from KUBE_SYSTEM_NAMESPACES
+var SystemNamespaces = getListOrDefault("KUBE_SYSTEM_NAMESPACES",
+	[]string{"kube-system", "kube-public", "kube-node-lease"})
+
+// NetworkProbeNamespace represents the namespace the network probes connect
+// from and to the target namespace, from KUBE_NETWORK_PROBE_NAMESPACE. The
+// probes are skipped if it is not defined.
+var NetworkProbeNamespace = os.Getenv("KUBE_NETWORK_PROBE_NAMESPACE")
+
+// PodSecurityExemptNamespaces represents the namespaces expected not to
+// enforce the baseline or restricted Pod Security level, from
+// KUBE_PSS_EXEMPT_NAMESPACES, e.g. "kube-system,monitoring"
+var PodSecurityExemptNamespaces = getList("KUBE_PSS_EXEMPT_NAMESPACES")
+
+// PortRange is an inclusive range of ports
+type PortRange struct {
+	Min int32
+	Max int32
+}
+
+// Contains reports whether port is in the range
+func (r PortRange) Contains(port int32) bool {
+	return port >= r.Min && port <= r.Max
+}
+
+// getPortRanges returns the port ranges listed in the environment variable.
+// A single port is a range of one, and invalid values are logged and left out.
+func getPortRanges(env string) []PortRange {
+	var ranges []PortRange
+	for _, value := range getList(env) {
+		bounds := strings.SplitN(value, "-", 2)
+		min, err := strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 32)
+		max := min
+		if err == nil && len(bounds) == 2 {
+			max, err = strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 32)
+		}
+		if err != nil || min > max {
+			log.Println("Ignoring invalid port range in " + env + ": " + value)
+			continue
+		}
+		ranges = append(ranges, PortRange{Min: int32(min), Max: int32(max)})
+	}
+	return ranges
+}
+
+// getListOrDefault returns the comma separated values of the environment
+// variable, or defaults if it is not defined
+func getListOrDefault(env string, defaults []string) []string {
+	if values := getList(env); values != nil {
+		return values
+	}
+	return defaults
+}
+
+// getList returns the comma separated values of the environment variable,
+// or nil if it is not defined
+func getList(env string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(env), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,297 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	g "github.com/onsi/ginkgo"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Workload controller kinds, as in the kind field of their objects
+const (
+	DeploymentKind            = "Deployment"
+	ReplicaSetKind            = "ReplicaSet"
+	ReplicationControllerKind = "ReplicationController"
+	DaemonSetKind             = "DaemonSet"
+	StatefulSetKind           = "StatefulSet"
+	JobKind                   = "Job"
+	CronJobKind               = "CronJob"
+)
+
+// ControllerKind returns the kind of the workload controller object, or an
+// empty string if it is not one
+func ControllerKind(obj runtime.Object) string {
+	switch obj.(type) {
+	case *appsv1.Deployment:
+		return DeploymentKind
+	case *appsv1.ReplicaSet:
+		return ReplicaSetKind
+	case *v1.ReplicationController:
+		return ReplicationControllerKind
+	case *appsv1.DaemonSet:
+		return DaemonSetKind
+	case *appsv1.StatefulSet:
+		return StatefulSetKind
+	case *batchv1.Job:
+		return JobKind
+	case *batchv1.CronJob:
+		return CronJobKind
+	}
+	return ""
+}
+
+// CreateController creates the workload controller object and registers
+// its deletion as a cleanup. The returned error wraps the API status, which
+// IsAdmissionDenied can inspect.
+func CreateController(ctx context.Context, clientset kubernetes.Interface,
+	obj runtime.Object, targetNamespace string) error {
+	var err error
+	opts := metav1.CreateOptions{}
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		_, err = clientset.AppsV1().Deployments(targetNamespace).Create(ctx, o, opts)
+	case *appsv1.ReplicaSet:
+		_, err = clientset.AppsV1().ReplicaSets(targetNamespace).Create(ctx, o, opts)
+	case *v1.ReplicationController:
+		_, err = clientset.CoreV1().ReplicationControllers(targetNamespace).Create(ctx, o, opts)
+	case *appsv1.DaemonSet:
+		_, err = clientset.AppsV1().DaemonSets(targetNamespace).Create(ctx, o, opts)
+	case *appsv1.StatefulSet:
+		_, err = clientset.AppsV1().StatefulSets(targetNamespace).Create(ctx, o, opts)
+	case *batchv1.Job:
+		_, err = clientset.BatchV1().Jobs(targetNamespace).Create(ctx, o, opts)
+	case *batchv1.CronJob:
+		_, err = clientset.BatchV1().CronJobs(targetNamespace).Create(ctx, o, opts)
+	default:
+		return fmt.Errorf("Failed to create controller: unsupported type %T", obj)
+	}
+	kind := ControllerKind(obj)
+	if err != nil {
+		return fmt.Errorf("Failed to create %s: %w", kind, err)
+	}
+	name := obj.(metav1.Object).GetName()
+	RegisterCleanup(kind+"/"+targetNamespace+"/"+name, func(ctx context.Context) error {
+		return DeleteController(ctx, clientset, kind, name, targetNamespace)
+	})
+	return nil
+}
+
+// DeleteController deletes the workload controller of the given kind, and
+// the pods and jobs it created
+func DeleteController(ctx context.Context, clientset kubernetes.Interface,
+	kind string, name string, targetNamespace string) error {
+	propagationPolicy := metav1.DeletePropagationForeground
+	opts := metav1.DeleteOptions{PropagationPolicy: &propagationPolicy}
+	var err error
+	switch kind {
+	case DeploymentKind:
+		err = clientset.AppsV1().Deployments(targetNamespace).Delete(ctx, name, opts)
+	case ReplicaSetKind:
+		err = clientset.AppsV1().ReplicaSets(targetNamespace).Delete(ctx, name, opts)
+	case ReplicationControllerKind:
+		err = clientset.CoreV1().ReplicationControllers(targetNamespace).Delete(ctx, name, opts)
+	case DaemonSetKind:
+		err = clientset.AppsV1().DaemonSets(targetNamespace).Delete(ctx, name, opts)
+	case StatefulSetKind:
+		err = clientset.AppsV1().StatefulSets(targetNamespace).Delete(ctx, name, opts)
+	case JobKind:
+		err = clientset.BatchV1().Jobs(targetNamespace).Delete(ctx, name, opts)
+	case CronJobKind:
+		err = clientset.BatchV1().CronJobs(targetNamespace).Delete(ctx, name, opts)
+	default:
+		return errors.New("Failed to delete controller: unsupported kind " + kind)
+	}
+	if err != nil && !kerr.IsNotFound(err) {
+		return errors.New("Failed to delete " + kind + ": " + err.Error())
+	}
+	UnregisterCleanup(kind + "/" + targetNamespace + "/" + name)
+	return nil
+}
+
+// PodCreation is what became of the pods a controller tried to create
+type PodCreation struct {
+	// Created is true once a pod labelled with the controller name exists
+	Created bool
+	// FailureMessage is the reason the pod creation failed, from the
+	// ReplicaFailure condition or the FailedCreate event
+	FailureMessage string
+}
+
+// GetPodCreation waits until the controller of the given kind has either
+// created a pod labelled k8s-app=name, or reported that it failed to. The
+// failure is read from the ReplicaFailure status condition of the
+// ReplicaSets and ReplicationControllers, which is where GetStatusCondition
+// finds it for Deployments, and from the FailedCreate events of the other
+// kinds, which have no such condition. A CronJob is followed through the
+// Jobs it schedules. If neither shows up after multiple retries, return an
+// error.
+func GetPodCreation(ctx context.Context, clientset kubernetes.Interface, kind string,
+	name string, targetNamespace string, retryCount int) (PodCreation, error) {
+	selector := metav1.ListOptions{LabelSelector: "k8s-app=" + name}
+	for i := 0; i <= retryCount; i++ {
+		pods, err := clientset.CoreV1().Pods(targetNamespace).List(ctx, selector)
+		if err != nil {
+			return PodCreation{}, errors.New("Failed to list pods: " + err.Error())
+		}
+		if len(pods.Items) != 0 {
+			return PodCreation{Created: true}, nil
+		}
+		message, err := podCreationFailure(ctx, clientset, kind, name, targetNamespace)
+		if err != nil {
+			return PodCreation{}, err
+		}
+		if message != "" {
+			return PodCreation{FailureMessage: message}, nil
+		}
+		log.Printf(g.CurrentGinkgoTestDescription().TestText+
+			": waiting for the %s %s to create its pods\n", kind, name)
+		// try every 10 seconds
+		if err := Sleep(ctx, 10*time.Second); err != nil {
+			return PodCreation{}, errors.New("GetPodCreation interrupted for " + kind + " " +
+				name + ": " + err.Error())
+		}
+	}
+	return PodCreation{}, errors.New("failed to find the pods or the pod creation failure of " +
+		kind + " " + name + " after multiple attempts")
+}
+
+// podCreationFailure returns the reason the controller failed to create its
+// pods, or an empty string if it has not reported a failure
+func podCreationFailure(ctx context.Context, clientset kubernetes.Interface, kind string,
+	name string, targetNamespace string) (string, error) {
+	selector := metav1.ListOptions{LabelSelector: "k8s-app=" + name}
+	switch kind {
+	case DeploymentKind:
+		rsList, err := ReplicaSetsByLabel(ctx, clientset, selector.LabelSelector, targetNamespace)
+		if err != nil {
+			return "", errors.New("Failed to list replicasets: " + err.Error())
+		}
+		for _, rs := range rsList.Items {
+			if message := replicaSetFailure(rs.Status.Conditions); message != "" {
+				return message, nil
+			}
+		}
+		return "", nil
+	case ReplicaSetKind:
+		rs, err := clientset.AppsV1().ReplicaSets(targetNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.New("Failed to get replicaset: " + err.Error())
+		}
+		return replicaSetFailure(rs.Status.Conditions), nil
+	case ReplicationControllerKind:
+		rc, err := clientset.CoreV1().ReplicationControllers(targetNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.New("Failed to get replicationcontroller: " + err.Error())
+		}
+		for _, cond := range rc.Status.Conditions {
+			if cond.Type == v1.ReplicationControllerReplicaFailure && cond.Status == v1.ConditionTrue {
+				return cond.Message, nil
+			}
+		}
+		return "", nil
+	case CronJobKind:
+		// the Job itself may be denied, or the pods of a scheduled Job
+		cronJob, err := clientset.BatchV1().CronJobs(targetNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.New("Failed to get cronjob: " + err.Error())
+		}
+		message, err := failedCreateEvent(ctx, clientset, cronJob.ObjectMeta, targetNamespace)
+		if err != nil || message != "" {
+			return message, err
+		}
+		jobs, err := clientset.BatchV1().Jobs(targetNamespace).List(ctx, selector)
+		if err != nil {
+			return "", errors.New("Failed to list jobs: " + err.Error())
+		}
+		for _, job := range jobs.Items {
+			message, err := failedCreateEvent(ctx, clientset, job.ObjectMeta, targetNamespace)
+			if err != nil || message != "" {
+				return message, err
+			}
+		}
+		return "", nil
+	case DaemonSetKind:
+		ds, err := clientset.AppsV1().DaemonSets(targetNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.New("Failed to get daemonset: " + err.Error())
+		}
+		return failedCreateEvent(ctx, clientset, ds.ObjectMeta, targetNamespace)
+	case StatefulSetKind:
+		sts, err := clientset.AppsV1().StatefulSets(targetNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.New("Failed to get statefulset: " + err.Error())
+		}
+		return failedCreateEvent(ctx, clientset, sts.ObjectMeta, targetNamespace)
+	case JobKind:
+		job, err := clientset.BatchV1().Jobs(targetNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.New("Failed to get job: " + err.Error())
+		}
+		return failedCreateEvent(ctx, clientset, job.ObjectMeta, targetNamespace)
+	}
+	return "", fmt.Errorf("Failed to get pod creation failure: unsupported kind %s", kind)
+}
+
+// replicaSetFailure returns the message of the ReplicaFailure condition
+// raised when pods fail to be created, if any
+func replicaSetFailure(conditions []appsv1.ReplicaSetCondition) string {
+	for _, cond := range conditions {
+		if cond.Type == appsv1.ReplicaSetReplicaFailure && cond.Status == v1.ConditionTrue {
+			return cond.Message
+		}
+	}
+	return ""
+}
+
+// failedCreateEvent returns the message of the latest FailedCreate event
+// recorded for the object, if any. Events are matched on the object uid, so
+// those of an earlier object of the same name are left out.
+func failedCreateEvent(ctx context.Context, clientset kubernetes.Interface, meta metav1.ObjectMeta,
+	targetNamespace string) (string, error) {
+	events, err := clientset.CoreV1().Events(targetNamespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.Set{
+			"involvedObject.uid": string(meta.UID),
+			"reason":             "FailedCreate",
+		}.AsSelector().String(),
+	})
+	if err != nil {
+		return "", errors.New("Failed to list events: " + err.Error())
+	}
+	var latest *v1.Event
+	for i := range events.Items {
+		if latest == nil || eventTime(events.Items[i]).After(eventTime(*latest)) {
+			latest = &events.Items[i]
+		}
+	}
+	if latest == nil {
+		return "", nil
+	}
+	return latest.Message, nil
+}
+
+// eventTime returns the last time the event was observed. Events recorded
+// through events.k8s.io/v1 leave LastTimestamp unset, and set their series
+// or EventTime instead.
+func eventTime(event v1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case event.Series != nil:
+		return event.Series.LastObservedTime.Time
+	}
+	return event.EventTime.Time
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 Oath, Inc.
+// Licensed under the terms of the Apache Version 2.0 License. See LICENSE file for terms.
+
+package util
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CleanupGracePeriod bounds how long RunCleanups may take to remove the
+// resources left behind by the checks
+var CleanupGracePeriod = 60 * time.Second
+
+var (
+	suiteCtx, cancelSuite = context.WithCancel(context.Background())
+
+	cleanupsMu sync.Mutex
+	cleanups   = map[string]func(ctx context.Context) error{}
+)
+
+// Context returns the context shared by all checks. It is cancelled once
+// the process receives SIGINT or SIGTERM.
+func Context() context.Context {
+	return suiteCtx
+}
+
+// Interrupted reports whether the suite context has been cancelled
+func Interrupted() bool {
+	return suiteCtx.Err() != nil
+}
+
+// SetupSignalHandler cancels the suite context on SIGINT or SIGTERM, so
+// in-flight checks stop waiting on the cluster and return an error.
+// Ginkgo handles the same signals by running AfterSuite, which is where the
+// registered cleanups are expected to run.
+func SetupSignalHandler() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-c
+		signal.Stop(c)
+		log.Println("Received " + sig.String() + ", cancelling in-flight checks")
+		cancelSuite()
+	}()
+}
+
+// Sleep waits for the given duration, or returns the context error as soon
+// as ctx is cancelled
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// RegisterCleanup registers fn under key to be run by RunCleanups.
+// Registering the same key again replaces the previous cleanup.
+func RegisterCleanup(key string, fn func(ctx context.Context) error) {
+	cleanupsMu.Lock()
+	defer cleanupsMu.Unlock()
+	cleanups[key] = fn
+}
+
+// UnregisterCleanup removes the cleanup registered under key, if any
+func UnregisterCleanup(key string) {
+	cleanupsMu.Lock()
+	defer cleanupsMu.Unlock()
+	delete(cleanups, key)
+}
+
+// RunCleanups runs every registered cleanup within gracePeriod. It uses a
+// fresh context, so cleanups still run after the suite context is cancelled.
+// Ginkgo runs AfterSuite on an interrupt while the interrupted spec is still
+// running, so cleanups registered meanwhile are run too, until none is left.
+// All cleanups are attempted, and the failures are returned as one error.
+func RunCleanups(gracePeriod time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	var errs []error
+	for {
+		pending := takeCleanups()
+		if len(pending) == 0 {
+			break
+		}
+		keys := make([]string, 0, len(pending))
+		for key := range pending {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if ctx.Err() != nil {
+			for _, key := range keys {
+				errs = append(errs, errors.New(key+": not cleaned up within the grace period"))
+			}
+			break
+		}
+		for _, key := range keys {
+			log.Println("Cleaning up " + key)
+			if err := pending[key](ctx); err != nil {
+				errs = append(errs, errors.New(key+": "+err.Error()))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// takeCleanups removes every registered cleanup and returns them
+func takeCleanups() map[string]func(ctx context.Context) error {
+	cleanupsMu.Lock()
+	defer cleanupsMu.Unlock()
+	taken := cleanups
+	cleanups = map[string]func(ctx context.Context) error{}
+	return taken
+}
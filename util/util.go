@@ -7,16 +7,19 @@
 package util
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"strings"
 	"time"
 
 	g "github.com/onsi/ginkgo"
+	"github.com/yahoo/k8s-sec-check/admission"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 	kerr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -25,6 +28,7 @@ import (
 const (
 	defaultNamespace      = "k8s-sec-check"
 	defaultServiceAccount = "k8s-sec-check"
+	defaultReportFormat   = "text"
 )
 
 // TargetNamespace represents Kubernetes namespace to run tests
@@ -33,6 +37,9 @@ var TargetNamespace = getTargetNamespace()
 // TargetServiceAccount represents Kubernetes service account
 var TargetServiceAccount = getTargetServiceAccount()
 
+// ReportFormat represents the format of the report written at the end of the run
+var ReportFormat = getReportFormat()
+
 // getTargetNamespace returns the value of KUBE_NAMESPACE,
 // or if that is not defined, set the default namespace
 func getTargetNamespace() string {
@@ -54,44 +61,64 @@ func getTargetServiceAccount() string {
 	return defaultServiceAccount
 }
 
-// CreateDeployment creates kubernetes deployment
-func CreateDeployment(clientset kubernetes.Interface, deployment *appsv1.Deployment, targetNamespace string) error {
-	_, err := clientset.AppsV1().Deployments(targetNamespace).Create(deployment)
+// getReportFormat returns the value of REPORT_FORMAT,
+// or if that is not defined, set the default report format
+func getReportFormat() string {
+	reportFormat := os.Getenv("REPORT_FORMAT")
+	if reportFormat != "" {
+		return reportFormat
+	}
+	return defaultReportFormat
+}
+
+// CreateDeployment creates kubernetes deployment and registers its deletion
+// as a cleanup, so it is removed even if the suite is interrupted
+func CreateDeployment(ctx context.Context, clientset kubernetes.Interface,
+	deployment *appsv1.Deployment, targetNamespace string) error {
+	_, err := clientset.AppsV1().Deployments(targetNamespace).
+		Create(ctx, deployment, metav1.CreateOptions{})
 	if err != nil {
 		return errors.New("Failed to create deployment: " + err.Error())
 	}
+	deploymentName := deployment.Name
+	RegisterCleanup("deployment/"+targetNamespace+"/"+deploymentName, func(ctx context.Context) error {
+		return DeleteDeployment(ctx, clientset, deploymentName, targetNamespace)
+	})
 	return nil
 }
 
 // DeleteDeployment deletes kubernetes deployment
-func DeleteDeployment(clientset kubernetes.Interface, deploymentName string, targetNamespace string) error {
+func DeleteDeployment(ctx context.Context, clientset kubernetes.Interface,
+	deploymentName string, targetNamespace string) error {
 	propagationPolicy := metav1.DeletePropagationForeground
-	err := clientset.AppsV1().Deployments(targetNamespace).Delete(deploymentName, &metav1.DeleteOptions{
+	err := clientset.AppsV1().Deployments(targetNamespace).Delete(ctx, deploymentName, metav1.DeleteOptions{
 		PropagationPolicy: &propagationPolicy,
 	})
 	if err != nil && !kerr.IsNotFound(err) {
 		return errors.New("Failed to delete deployment: " + err.Error())
 	}
+	UnregisterCleanup("deployment/" + targetNamespace + "/" + deploymentName)
 	return nil
 }
 
 // ReplicaSetsByLabel returns rs list by labelSelectorValue and targetNamespace
-func ReplicaSetsByLabel(clientset kubernetes.Interface, labelSelectorValue string, targetNamespace string) (*v1beta1.ReplicaSetList, error) {
-	return clientset.ExtensionsV1beta1().
+func ReplicaSetsByLabel(ctx context.Context, clientset kubernetes.Interface,
+	labelSelectorValue string, targetNamespace string) (*appsv1.ReplicaSetList, error) {
+	return clientset.AppsV1().
 		ReplicaSets(targetNamespace).
-		List(metav1.ListOptions{
+		List(ctx, metav1.ListOptions{
 			LabelSelector: labelSelectorValue,
 		})
 }
 
 // IsPrivilegedContainerCreated gets the replicaset and match status failure
 // condition and status failure reason
-func IsPrivilegedContainerCreated(rsList *v1beta1.ReplicaSetList,
+func IsPrivilegedContainerCreated(rsList *appsv1.ReplicaSetList,
 	statusReason string, statusCondition string) bool {
 	for _, rs := range rsList.Items {
 		for _, cond := range rs.Status.Conditions {
 			if cond.Reason == statusReason &&
-				cond.Type == v1beta1.ReplicaSetReplicaFailure &&
+				cond.Type == appsv1.ReplicaSetReplicaFailure &&
 				strings.Contains(cond.Message, statusCondition) {
 				return false
 			}
@@ -101,39 +128,212 @@ func IsPrivilegedContainerCreated(rsList *v1beta1.ReplicaSetList,
 	return true
 }
 
-// CreatePod creates kubernetes pod
-func CreatePod(clientset kubernetes.Interface, pod *v1.Pod, targetNamespace string) error {
-	_, err := clientset.CoreV1().Pods(targetNamespace).Create(pod)
+// CreatePod creates kubernetes pod and registers its deletion as a cleanup,
+// so it is removed even if the suite is interrupted
+func CreatePod(ctx context.Context, clientset kubernetes.Interface, pod *v1.Pod, targetNamespace string) error {
+	_, err := clientset.CoreV1().Pods(targetNamespace).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
 		return errors.New("Failed to create pod: " + err.Error())
 	}
+	podName := pod.Name
+	RegisterCleanup("pod/"+targetNamespace+"/"+podName, func(ctx context.Context) error {
+		return DeletePod(ctx, clientset, podName, targetNamespace)
+	})
 	return nil
 }
 
 // DeletePod deletes kubernetes pod
-func DeletePod(clientset kubernetes.Interface, podName string, targetNamespace string) error {
+func DeletePod(ctx context.Context, clientset kubernetes.Interface, podName string, targetNamespace string) error {
 	propagationPolicy := metav1.DeletePropagationForeground
-	err := clientset.CoreV1().Pods(targetNamespace).Delete(podName, &metav1.DeleteOptions{
+	err := clientset.CoreV1().Pods(targetNamespace).Delete(ctx, podName, metav1.DeleteOptions{
 		PropagationPolicy: &propagationPolicy,
 	})
 	if err != nil && !kerr.IsNotFound(err) {
 		return errors.New("Failed to delete pod: " + err.Error())
 	}
+	UnregisterCleanup("pod/" + targetNamespace + "/" + podName)
+	return nil
+}
+
+// CreateNetworkPolicy creates the NetworkPolicy and registers its deletion
+// as a cleanup, so it is removed even if the suite is interrupted
+func CreateNetworkPolicy(ctx context.Context, clientset kubernetes.Interface,
+	policy *networkingv1.NetworkPolicy, targetNamespace string) error {
+	_, err := clientset.NetworkingV1().NetworkPolicies(targetNamespace).Create(ctx, policy, metav1.CreateOptions{})
+	if err != nil {
+		return errors.New("Failed to create networkpolicy: " + err.Error())
+	}
+	policyName := policy.Name
+	RegisterCleanup("networkpolicy/"+targetNamespace+"/"+policyName, func(ctx context.Context) error {
+		return DeleteNetworkPolicy(ctx, clientset, policyName, targetNamespace)
+	})
+	return nil
+}
+
+// DeleteNetworkPolicy deletes the NetworkPolicy
+func DeleteNetworkPolicy(ctx context.Context, clientset kubernetes.Interface,
+	policyName string, targetNamespace string) error {
+	err := clientset.NetworkingV1().NetworkPolicies(targetNamespace).Delete(ctx, policyName, metav1.DeleteOptions{})
+	if err != nil && !kerr.IsNotFound(err) {
+		return errors.New("Failed to delete networkpolicy: " + err.Error())
+	}
+	UnregisterCleanup("networkpolicy/" + targetNamespace + "/" + policyName)
 	return nil
 }
 
+// DryRunCreatePod submits the pod for creation with server-side dry-run, so
+// it goes through admission without being persisted. It returns the pod as
+// admitted, after mutation and defaulting, or the error wrapping the API
+// status, which IsAdmissionDenied can inspect.
+func DryRunCreatePod(ctx context.Context, clientset kubernetes.Interface,
+	pod *v1.Pod, targetNamespace string) (*v1.Pod, error) {
+	admitted, err := clientset.CoreV1().Pods(targetNamespace).Create(ctx, pod, metav1.CreateOptions{
+		DryRun: []string{metav1.DryRunAll},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create pod: %w", err)
+	}
+	return admitted, nil
+}
+
+// DryRunUpdatePod submits the update of an existing pod with server-side
+// dry-run. The pod is expected to carry the resourceVersion it was read
+// with. The returned error wraps the API status, like DryRunCreatePod.
+func DryRunUpdatePod(ctx context.Context, clientset kubernetes.Interface,
+	pod *v1.Pod, targetNamespace string) (*v1.Pod, error) {
+	updated, err := clientset.CoreV1().Pods(targetNamespace).Update(ctx, pod, metav1.UpdateOptions{
+		DryRun: []string{metav1.DryRunAll},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to update pod: %w", err)
+	}
+	return updated, nil
+}
+
+// DryRunUpdateEphemeralContainers submits the ephemeral containers of an
+// existing pod through the ephemeralcontainers subresource, the way
+// kubectl debug adds them, with server-side dry-run. The returned error
+// wraps the API status, like DryRunCreatePod.
+func DryRunUpdateEphemeralContainers(ctx context.Context, clientset kubernetes.Interface,
+	pod *v1.Pod, targetNamespace string) (*v1.Pod, error) {
+	updated, err := clientset.CoreV1().Pods(targetNamespace).
+		UpdateEphemeralContainers(ctx, pod.Name, pod, metav1.UpdateOptions{
+			DryRun: []string{metav1.DryRunAll},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to update ephemeral containers: %w", err)
+	}
+	return updated, nil
+}
+
+// GetPod returns the pod as currently stored
+func GetPod(ctx context.Context, clientset kubernetes.Interface, podName string,
+	targetNamespace string) (*v1.Pod, error) {
+	pod, err := clientset.CoreV1().Pods(targetNamespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.New("Failed to get pod: " + err.Error())
+	}
+	return pod, nil
+}
+
+// PodIP returns the IP of a running pod labelled k8s-app=name, e.g. a pod
+// of the deployment name
+func PodIP(ctx context.Context, clientset kubernetes.Interface, name string,
+	targetNamespace string) (string, error) {
+	pods, err := clientset.CoreV1().Pods(targetNamespace).
+		List(ctx, metav1.ListOptions{LabelSelector: "k8s-app=" + name})
+	if err != nil {
+		return "", errors.New("Failed to list pods: " + err.Error())
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == v1.PodRunning && pod.Status.PodIP != "" {
+			return pod.Status.PodIP, nil
+		}
+	}
+	return "", errors.New("no running pod of " + name + " has an IP")
+}
+
+// AdmissionOutcome is how admission handled a probe object
+type AdmissionOutcome string
+
+const (
+	// Denied : admission refused the object
+	Denied AdmissionOutcome = "denied"
+	// Mutated : admission rewrote the object into a compliant one
+	Mutated AdmissionOutcome = "mutated"
+	// Admitted : admission accepted the object as it was
+	Admitted AdmissionOutcome = "admitted"
+)
+
+// ProbePod submits the pod with DryRunCreatePod and tells whether admission
+// denied it, rewrote it so that compliant holds for the admitted pod, or
+// admitted it as is. It returns the admitted pod, if any. Only errors other
+// than a denial are returned.
+func ProbePod(ctx context.Context, clientset kubernetes.Interface, pod *v1.Pod,
+	targetNamespace string, compliant func(pod *v1.Pod) bool) (AdmissionOutcome, *v1.Pod, error) {
+	admitted, err := DryRunCreatePod(ctx, clientset, pod, targetNamespace)
+	if err != nil {
+		if IsAdmissionDenied(err) {
+			return Denied, nil, nil
+		}
+		return "", nil, err
+	}
+	if compliant(admitted) {
+		return Mutated, admitted, nil
+	}
+	return Admitted, admitted, nil
+}
+
+// IsAdmissionDenied reports whether err is the API server refusing to admit
+// an object, as opposed to failing to process the request. Denials are
+// recognised by their message: webhooks such as Kyverno's deny with a 400
+// status rather than 403, and RBAC or ResourceQuotas forbid requests without
+// any admission policy taking part.
+func IsAdmissionDenied(err error) bool {
+	var status kerr.APIStatus
+	return errors.As(err, &status) && admission.IsDenied(status.Status().Message)
+}
+
+// PodLogs waits until the pod has run to completion, and returns its logs
+func PodLogs(ctx context.Context, clientset kubernetes.Interface, podName string,
+	targetNamespace string, retryCount int) (string, error) {
+	for i := 0; i <= retryCount; i++ {
+		pod, err := clientset.CoreV1().Pods(targetNamespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.New("Failed to get pod: " + err.Error())
+		}
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			logs, err := clientset.CoreV1().Pods(targetNamespace).
+				GetLogs(podName, &v1.PodLogOptions{}).DoRaw(ctx)
+			if err != nil {
+				return "", errors.New("Failed to get pod logs: " + err.Error())
+			}
+			return string(logs), nil
+		}
+		log.Println(g.CurrentGinkgoTestDescription().TestText + ": waiting for the pod to complete...")
+		// try every 10 seconds
+		if err := Sleep(ctx, 10*time.Second); err != nil {
+			return "", errors.New("PodLogs interrupted for pod " + podName + ": " + err.Error())
+		}
+	}
+	return "", errors.New("pod " + podName + " did not complete even after multiple retryCount")
+}
+
 // CheckReadyReplicas will wait until the resource is fully rolled out with all replicas
-func CheckReadyReplicas(clientset kubernetes.Interface, deploymentName string,
+func CheckReadyReplicas(ctx context.Context, clientset kubernetes.Interface, deploymentName string,
 	targetNamespace string, retryCount int) error {
 	for i := 0; i <= retryCount; i++ {
 		deployment, err := clientset.AppsV1().Deployments(targetNamespace).
-			Get(deploymentName, metav1.GetOptions{})
+			Get(ctx, deploymentName, metav1.GetOptions{})
 		if err != nil {
 			return errors.New("Failed to get deployment: " + err.Error())
 		}
 		log.Println(g.CurrentGinkgoTestDescription().TestText + ": waiting for the ready replicas...")
 		// try every 30 seconds
-		time.Sleep(30 * time.Second)
+		if err := Sleep(ctx, 30*time.Second); err != nil {
+			return errors.New("CheckReadyReplicas interrupted for deployment " +
+				deploymentName + ": " + err.Error())
+		}
 
 		// check if number of ready replica count is matching desired replicas.
 		if deployment.Status.ReadyReplicas == *deployment.Spec.Replicas {
@@ -149,10 +349,11 @@ func CheckReadyReplicas(clientset kubernetes.Interface, deploymentName string,
 // GetStatusCondition waits until status conditions are availabe for a
 // given replica set. If not found after multiple retries, return an error
 // otherwise return the replicasetList instance.
-func GetStatusCondition(clientset kubernetes.Interface, deploymentName string) (*v1beta1.ReplicaSetList, error) {
+func GetStatusCondition(ctx context.Context, clientset kubernetes.Interface,
+	deploymentName string) (*appsv1.ReplicaSetList, error) {
 	retryCount := 3
 	for i := 0; i <= retryCount; i++ {
-		rsList, err := ReplicaSetsByLabel(clientset,
+		rsList, err := ReplicaSetsByLabel(ctx, clientset,
 			"k8s-app="+deploymentName, TargetNamespace)
 		// fail if any other error happens while fetching replicaset
 		if err != nil {
@@ -163,7 +364,10 @@ func GetStatusCondition(clientset kubernetes.Interface, deploymentName string) (
 			": waiting for replicaset and status condition to be available "+
 			"for deployment: %v\n", deploymentName)
 		// sleep 30 seconds, wait for replication controller to create replicaset
-		time.Sleep(30 * time.Second)
+		if err := Sleep(ctx, 30*time.Second); err != nil {
+			return nil, errors.New("interrupted while waiting for the replicaset " +
+				"status condition: " + err.Error())
+		}
 		if len(rsList.Items) != 0 && len(rsList.Items[0].Status.Conditions) != 0 {
 			return rsList, nil
 		}